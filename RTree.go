@@ -3,11 +3,12 @@
 // Building the index requires exactly 8 allocations and approximately 40 bytes per coordinate.
 // That is, an index for 1 million points requires approximately 40Mb in the heap.
 //
-// To achieve this speed, the index has three restrictions. It is static, once built it cannot be changed.
-// It only accepts points coordinates, no bboxes, lines or ids. And it only accepts (for now) one query, closest point to a given coordinate.
+// To achieve this speed, the index has three restrictions. It is static, once built it cannot be changed apart from deleting points (DynamicRTree accepts new points on top of static trees).
+// It only accepts points coordinates (optionally with an id per point). Rectangles and line segments are indexed by the separate BBoxRTree and SegmentRTree. And it only accepts (for now) a few queries: closest point or k closest points to a given coordinate, points within a distance, points within a bbox and points within a polygon. Distances are euclidean by default, Options.Metric selects others (Manhattan, Chebyshev, weighted euclidean) and Options.Geodesic great-circle distance for longitude, latitude points.
 //
 // Beware, to achieve top performance one of the hot functions has been rewritten in assembly.
-// Library works in x86 but it probably won't work in other architectures. PRs are welcome to fix this deficiency.
+// The assembly is only used in amd64, other architectures fall back to an equivalent (and slower) go implementation.
+// The go implementation can be forced with the purego build tag.
 //
 // Basic Usage
 //
@@ -48,28 +49,37 @@
 package SimpleRTree
 
 import (
-	"bytes"
 	"fmt"
 	"log"
 	"math"
-	"strings"
-	"text/template"
 	"unsafe"
 	"sync"
 	"sort"
 )
 
-const MAX_POSSIBLE_SIZE = 9
+// MAX_POSSIBLE_SIZE is the largest fan-out (Options.MAX_ENTRIES) supported
+const MAX_POSSIBLE_SIZE = 64
+
+// DEFAULT_MAX_ENTRIES is the fan-out used by New and when Options.MAX_ENTRIES is 0
+const DEFAULT_MAX_ENTRIES = 9
 
 // SimpleRTree is the main structure of the library
 type SimpleRTree struct {
 	options Options
 	nodes   []rNode
 	points  FlatPoints
+	ids     []int // ids[i] is the id of the point at position i of points. nil if the tree was loaded without ids
 	built   bool
+	height  int
 	queuePool         sync.Pool
 	unsafeQueue         searchQueue // Only used in unsafe mode
+	edgesPool         sync.Pool // *[]int buffers of edges for SearchPolygon
+	unsafeEdges       []int // Only used in unsafe mode
 	sorterBuffer      []int // floyd rivest requires a bucket, we allocate it once and reuse
+	deleted     []bool // deleted[i] is true if the point at position i was deleted. nil until the first deletion
+	nDeleted    int
+	idPositions map[int]int // position of every id, only built if DeleteByID is called
+	mapped      []byte // file mapped by OpenMapped, nodes, points, ids and deleted point to it
 }
 
 // FlatPoints is the input format for coordinates
@@ -78,31 +88,37 @@ type SimpleRTree struct {
 // []float64{0, 0, 2, 4} corresponds to the points (0, 0) and (2, 4)
 //
 // Note: rtree is assumed to have sole access to the array, it will modify the underlying order and it
-// will return wrong results if the elements are modified
+// will return wrong results if the elements are modified. Unless the tree is created with Options.CopyPoints
 type FlatPoints []float64
 
 type TreeType uint8
 
 const (
 	STR = iota
-	HILBERT
+	HILBERT // Points are packed in the order of a Hilbert curve
+	ZORDER // Points are packed in Z-order, as given by GeoHash
 )
 
 
 // SimpleRTree can be slightly tuned
 type Options struct {
 	UnsafeConcurrencyMode bool // Set this parameter to true if you only intend to access the R tree from one go routine. FindNearestPoint will be faster and it will make no allocations
-	MAX_ENTRIES int
+	MAX_ENTRIES int // Maximum number of children per node, between 2 and MAX_POSSIBLE_SIZE.
 	TreeType TreeType
 	RTreePool *sync.Pool // If a lot of RTrees are being created you can provide a pool to the tree. On destroy the underlying memory space will be saved back to the pool, so next tree can use it
+	CopyPoints bool // Set this parameter to true to leave the array of points (and ids) untouched. Points are copied to memory owned by the tree, which is taken from RTreePool if given
+	CompactThreshold float64 // If greater than 0, Delete calls Compact once TombstoneRatio exceeds it. Must be at most 1
+	WorldBBox *[4]float64 // minX, minY, maxX, maxY used to place points on the curve of HILBERT and ZORDER trees. If nil the extent of the points is used. Points outside are clamped to the border
+	Geodesic bool // Set this parameter to true if points are longitude (x) and latitude (y) in degrees. Nearest, k nearest and within queries then use the great-circle distance in meters, wrapping across ±180° longitude, and dsquared and returned distances are meters squared. Search and SearchPolygon are not affected
+	Metric Metric // Distance used by nearest, k nearest and within queries, for example Manhattan{}. dsquared and returned distances are in its units. If nil (or Euclidean{}) the squared euclidean distance is used, which is the fast path. Cannot be combined with Geodesic
 }
 
 type rNode struct {
 	nodeType         nodeType
-	nChildren        int8
-	// Here we save firstChild - firstNode. That means that there is there is a theoretical upper limit to the tree of
-	// maxuint32 / node_size = 4294967295 / 40 = 107374182 ~ 100M
-	firstChildOffset uint32
+	nChildren        uint8
+	// Index of the first child, in nodes for default nodes and in points for preleaf nodes.
+	// Storing indexes instead of byte offsets keeps the node in 40 bytes and allows trees of up to maxPoints
+	firstChild       uint32
 	BBox             rVectorBBox
 }
 type nodeType int8
@@ -115,10 +131,34 @@ var node_size = unsafe.Sizeof(rNode{})
 var flat_point_size =unsafe.Sizeof([2]float64{})
 var float_size = uintptr(unsafe.Sizeof([1]float64{}))
 
+// maxPoints is the largest number of points in a tree. Children are referenced with uint32 indexes
+// and there can be more nodes than points for small MAX_ENTRIES, so we keep some room for them
+const maxPoints = math.MaxUint32 / 2
+
+// nodeAddress returns the address of node i of the array of nodes starting at base.
+// The multiplication is done in uintptr, so offsets go beyond 32 bits
+func nodeAddress(base uintptr, i uint32) uintptr {
+	return base + uintptr(i)*node_size
+}
+
+// pointAddress returns the address of point i of the array of points starting at base
+func pointAddress(base uintptr, i uint32) uintptr {
+	return base + uintptr(i)*flat_point_size
+}
+
+func checkSize(nPoints int) error {
+	if nPoints > maxPoints {
+		return fmt.Errorf("%w: %d points, maximum is %d", ErrTooManyPoints, nPoints, maxPoints)
+	}
+	return nil
+}
+
 type pooledMem struct {
 	sorterBuffer []int
 	sq searchQueue
 	nodes []rNode
+	points FlatPoints // only if points were copied
+	ids []int
 }
 
 // Structure used to constructing the ndoe
@@ -130,21 +170,17 @@ type nodeConstruct struct {
 // New returns an instance of an RTree with default options
 func New() *SimpleRTree {
 	defaultOptions := Options{
-		MAX_ENTRIES: MAX_POSSIBLE_SIZE,
+		MAX_ENTRIES: DEFAULT_MAX_ENTRIES,
 	}
 	return NewWithOptions(defaultOptions)
 }
 
-// NewWithOptions returns an instance of an RTree with given options o
+// NewWithOptions returns an instance of an RTree with given options o.
+// It panics if the options are not valid, see NewWithOptionsErr
 func NewWithOptions(o Options) *SimpleRTree {
-	r := &SimpleRTree{
-		options: o,
-	}
-	if o.MAX_ENTRIES > MAX_POSSIBLE_SIZE {
-		panic(fmt.Sprintf("Cannot exceed %d for size", MAX_POSSIBLE_SIZE))
-	}
-	if o.MAX_ENTRIES == 0 {
-		r.options.MAX_ENTRIES = MAX_POSSIBLE_SIZE
+	r, err := NewWithOptionsErr(o)
+	if err != nil {
+		panic(err.Error())
 	}
 	return r
 }
@@ -161,21 +197,42 @@ func NewWithOptions(o Options) *SimpleRTree {
 //   r2 := SimpleRTree.NewWithOptions(SimpleRTree.Options{RTreePool: pool})
 func (r *SimpleRTree) Destroy () {
 	if r.options.RTreePool != nil {
-		r.options.RTreePool.Put(
-			&pooledMem{
-				sorterBuffer: r.sorterBuffer,
-				sq: r.unsafeQueue,
-				nodes: r.nodes,
-			},
-		)
+		mem := &pooledMem{
+			sorterBuffer: r.sorterBuffer,
+			sq: r.unsafeQueue,
+			nodes: r.nodes,
+		}
+		if r.mapped != nil { // mapped memory cannot be reused by other trees
+			mem.nodes = nil
+		}
+		if r.options.CopyPoints {
+			mem.points = r.points
+			mem.ids = r.ids
+		}
+		r.options.RTreePool.Put(mem)
 	}
 }
 // Load accepts points, an flat array of coordinates and builds the RTree
 //
 // Note: rtree is assumed to have sole access to the array, it will modify the underlying order and it
-// will return wrong results if the elements are modified
+// will return wrong results if the elements are modified. Use Options.CopyPoints to avoid this
 func (r *SimpleRTree) Load(points FlatPoints) *SimpleRTree {
-	return r.load(points, false)
+	return r.load(points, nil, false)
+}
+
+// LoadWithIDs works as Load but it keeps track of an id for every point, ids[i] is the id of the point i.
+// Ids are reordered together with the points, so that queries like FindNearestPointID can return the id of the point found.
+// If ids is nil, the id of each point is its original position in points
+//   r := SimpleRTree.New().LoadWithIDs(fp, nil)
+//   id, x1, y1, d1 := r.FindNearestPointID(x, y)
+//   // x1, y1 == fp[2 * id], fp[2 * id + 1] before loading
+//
+// Note: as with points, rtree is assumed to have sole access to ids
+func (r *SimpleRTree) LoadWithIDs(points FlatPoints, ids []int) *SimpleRTree {
+	if ids == nil {
+		ids = identityIDs(points.Len())
+	}
+	return r.load(points, ids, false)
 }
 
 // LoadSortedArray accepts a sorted flat array of coordinates and builds the RTree.
@@ -183,12 +240,31 @@ func (r *SimpleRTree) Load(points FlatPoints) *SimpleRTree {
 // points must have been sorted lexicographically. That is
 // (x1, y1) < (x2, y2) if x1 < x2 or x1 === x2 and y1 < y2
 //
-// In case the tree is a hilbert tree (created with NewWithOptions) then points are assumed to be sorted wrt to the geohash
+// In case the tree is a hilbert tree (created with NewWithOptions) then points are assumed to be sorted along a Hilbert curve,
+// and for a Z-order tree along a Z-order curve. The curve runs over a 32 bit grid covering Options.WorldBBox, or the bbox of
+// the points if it is not set, so HilbertHash and GeoHash, which use a fixed lat, lng grid, give a different order.
+// SortForLoad sorts the points as expected
 //
 // Note: rtree is assumed to have sole access to the array, it will modify the underlying order and it
 // will return wrong results if the elements are modified
 func (r *SimpleRTree) LoadSortedArray(points FlatPoints) *SimpleRTree {
-	return r.load(points, true)
+	return r.load(points, nil, true)
+}
+
+// SortForLoad sorts points in the order LoadSortedArray expects for a tree with the options of r.
+// That is lexicographically for STR trees and along the curve for HILBERT and ZORDER trees
+//  r := SimpleRTree.NewWithOptions(SimpleRTree.Options{TreeType: SimpleRTree.HILBERT})
+//  r.SortForLoad(fp)
+//  r.LoadSortedArray(fp)
+func (r *SimpleRTree) SortForLoad(points FlatPoints) {
+	if points.Len() == 0 {
+		return
+	}
+	if r.options.TreeType == STR {
+		sort.Sort(lexicographicSorter(points))
+		return
+	}
+	r.sortHilbert(points, nil)
 }
 
 // FindNearestPoint will return the coordinates of the closest point
@@ -211,17 +287,90 @@ func (r *SimpleRTree) FindNearestPoint(x, y float64) (x1, y1, d1 float64) {
 //  x1, y1, d1, found := r.FindNearestPointWithin(x, y, 4)
 // (x1 - x) * (x1 - x) + (y1 - y) * (y1 - y) < 4
 func (r *SimpleRTree) FindNearestPointWithin(x, y, dsquared float64) (x1, y1, d1 float64, found bool) {
+	i, d1, found := r.findNearestPointWithin(x, y, dsquared)
+	if !found {
+		return
+	}
+	x1, y1 = r.points.GetPointAt(i)
+	return
+}
+
+// FindKNearestPoints appends to dst the k closest points to the provided coordinates x and y.
+// Points are appended in increasing order of distance, so the first point appended is the one FindNearestPoint would return.
+// If the tree holds less than k points all of them are appended.
+// No allocations are made as long as dst has enough capacity for the k points
+//  dst := make(SimpleRTree.FlatPoints, 0, 2 * k)
+//  dst = r.FindKNearestPoints(x, y, k, dst[0:0])
+func (r *SimpleRTree) FindKNearestPoints(x, y float64, k int, dst FlatPoints) FlatPoints {
+	return r.FindKNearestPointsWithin(x, y, math.Inf(1), k, dst)
+}
+
+// FindKNearestPointsWithin works as FindKNearestPoints but only considers points
+// within the distance squared dsquared. That is |x1 - x|**2 + |y1 - y|**2 <= dsquared,
+// so less than k points might be appended to dst
+func (r *SimpleRTree) FindKNearestPointsWithin(x, y, dsquared float64, k int, dst FlatPoints) FlatPoints {
+	r.findKNearestPointsWithin(x, y, dsquared, k, func (i int) {
+		x1, y1 := r.points.GetPointAt(i)
+		dst = append(dst, x1, y1)
+	})
+	return dst
+}
+
+// Search appends to dst all the points that lie within the bbox given by minX, minY, maxX, maxY.
+// Points on the border of the bbox are included. Points are appended in no particular order.
+// No allocations are made as long as dst has enough capacity for the result
+//  dst = r.Search(0, 0, 1, 1, dst[0:0])
+func (r *SimpleRTree) Search(minX, minY, maxX, maxY float64, dst FlatPoints) FlatPoints {
+	r.search(rBBox{MinX: minX, MinY: minY, MaxX: maxX, MaxY: maxY}, func (i int) {
+		x1, y1 := r.points.GetPointAt(i)
+		dst = append(dst, x1, y1)
+	})
+	return dst
+}
+
+// FindPointsWithin appends to dst all the points within the distance squared dsquared of the coordinates x and y.
+// That is, every point satisfying |x1 - x|**2 + |y1 - y|**2 <= dsquared.
+// Points are appended in no particular order, they can be sorted afterwards with r.SortByDistance.
+// No allocations are made as long as dst has enough capacity for the result
+//  start := len(dst)
+//  dst = r.FindPointsWithin(x, y, 4, dst)
+//  r.SortByDistance(x, y, dst[start:])
+func (r *SimpleRTree) FindPointsWithin(x, y, dsquared float64, dst FlatPoints) FlatPoints {
+	r.findPointsWithin(x, y, dsquared, func (i int) {
+		x1, y1 := r.points.GetPointAt(i)
+		dst = append(dst, x1, y1)
+	})
+	return dst
+}
+
+// Queries work on the position of the points in the underlying array, which is then translated to
+// coordinates or ids by the public methods.
+
+func (r *SimpleRTree) findNearestPointWithin(x, y, dsquared float64) (index int, d1 float64, found bool) {
+	if len(r.nodes) == 0 {
+		return
+	}
+	sq := r.getQueue()
+	index, d1, found, sq = r.findNearestPointWithQueue(sq, x, y, dsquared, false, nil)
+	r.putQueue(sq)
+	return
+}
+
+// findNearestPointWithQueue performs the nearest point query on the given queue, which is returned since it might grow.
+// If batched is true the distances to the children of a node are computed together with vectorComputeDistances4
+// trace, if not nil, is called with the index of every node that is explored, it is only used to draw queries (see ExportSVGQuery)
+func (r *SimpleRTree) findNearestPointWithQueue(sq searchQueue, x, y, dsquared float64, batched bool, trace func (nodeIndex int)) (index int, d1 float64, found bool, _ searchQueue) {
+	if m := r.options.metric(); m != nil {
+		return r.findNearestPointMetric(sq, m, x, y, dsquared, trace)
+	}
 	var minItem searchQueueItem
 	distanceLowerBound := math.Inf(1)
 	// if bbox is further from this bound then we don't explore it
 	distanceUpperBound := dsquared
-	var sq searchQueue
-	if r.options.UnsafeConcurrencyMode {
-		sq = r.unsafeQueue
-	} else {
-		sq = r.queuePool.Get().(searchQueue)
-	}
 	sq = sq[0:0]
+	var minds, maxds [MAX_POSSIBLE_SIZE]float64 // only for batched
+	// with deleted points maxd no longer guarantees a point within that distance
+	deleted := r.deleted
 
 	rootNode := &r.nodes[0]
 	unsafeRootLeafNode := uintptr(unsafe.Pointer(&r.points[0]))
@@ -245,25 +394,43 @@ func (r *SimpleRTree) FindNearestPointWithin(x, y, dsquared float64) (x1, y1, d1
 			found = true
 			continue
 		}
+		if trace != nil {
+			trace(int((item.node - unsafeRootNode) / node_size))
+		}
 		switch node.nodeType {
 		case preleaf_node:
-			f := unsafeRootLeafNode + uintptr(node.firstChildOffset)
-			var i int8
+			f := pointAddress(unsafeRootLeafNode, node.firstChild)
+			index := int(node.firstChild)
+			var i uint8
 			for i = node.nChildren; i>0; i-- {
 				px := *(*float64)(unsafe.Pointer(f))
 				f = f + float_size
 				py := *(*float64)(unsafe.Pointer(f))
 
 				d := computeLeafDistance(px, py, x, y)
-				if d <= distanceUpperBound {
-					sq = append(sq, searchQueueItem{node: uintptr(unsafe.Pointer(nil)), px: px, py: py, distance: d})
+				if d <= distanceUpperBound && (deleted == nil || !deleted[index]) {
+					sq = append(sq, searchQueueItem{node: uintptr(unsafe.Pointer(nil)), index: index, distance: d})
 					distanceUpperBound = d
 				}
 				f = f + float_size
+				index++
 			}
 		default:
-			f := unsafeRootNode + uintptr(node.firstChildOffset)
-			var i int8
+			f := nodeAddress(unsafeRootNode, node.firstChild)
+			if batched {
+				computeChildrenDistances(f, int(node.nChildren), x, y, &minds, &maxds)
+				for i := 0; i < int(node.nChildren); i++ {
+					if minds[i] <= distanceUpperBound {
+						sq = append(sq, searchQueueItem{node: f, distance: minds[i]})
+						if maxds[i] < distanceUpperBound && deleted == nil {
+							distanceUpperBound = maxds[i]
+						}
+					}
+					f = f + node_size
+				}
+				continue
+			}
+			var i uint8
 			for i = node.nChildren; i>0; i-- {
 				n := (*rNode)(unsafe.Pointer(f))
 				mind, maxd := vectorComputeDistances(n.BBox, x, y)
@@ -271,7 +438,7 @@ func (r *SimpleRTree) FindNearestPointWithin(x, y, dsquared float64) (x1, y1, d1
 					sq = append(sq, searchQueueItem{node: uintptr(unsafe.Pointer(n)), distance: mind})
 					// Distance to one of the corners is lower than the upper bound
 					// so there must be a point at most within distanceUpperBound
-					if maxd < distanceUpperBound {
+					if maxd < distanceUpperBound && deleted == nil {
 						distanceUpperBound = maxd
 					}
 				}
@@ -280,34 +447,225 @@ func (r *SimpleRTree) FindNearestPointWithin(x, y, dsquared float64) (x1, y1, d1
 		}
 	}
 
-	// return heap
+	if !found {
+		return index, d1, found, sq
+	}
+	return minItem.index, distanceUpperBound, found, sq
+}
+
+// findKNearestPointsWithin calls visit with the k closest points in increasing order of distance
+func (r *SimpleRTree) findKNearestPointsWithin(x, y, dsquared float64, k int, visit func (index int)) {
+	if k <= 0 || len(r.nodes) == 0 {
+		return
+	}
+	if m := r.options.metric(); m != nil {
+		r.findKNearestPointsMetric(m, x, y, dsquared, k, visit)
+		return
+	}
+	sq := r.getQueue()
+
+	rootNode := &r.nodes[0]
+	unsafeRootLeafNode := uintptr(unsafe.Pointer(&r.points[0]))
+	unsafeRootNode := uintptr(unsafe.Pointer(rootNode))
+	sq = append(sq, searchQueueItem{node: uintptr(unsafe.Pointer(rootNode)), distance: 0})
+	deleted := r.deleted
+
+	found := 0
+	for sq.Len() > 0 {
+		sq.PreparePop()
+		item := sq[sq.Len() - 1]
+		sq = sq[0: sq.Len() - 1]
+
+		node := (*rNode)(unsafe.Pointer(item.node))
+		if node == nil { // Leaf
+			// Items are popped in order of distance and bboxes are lower bounds
+			// so no point left in the queue can be closer than this one
+			visit(item.index)
+			found++
+			if found == k {
+				break
+			}
+			continue
+		}
+		switch node.nodeType {
+		case preleaf_node:
+			f := pointAddress(unsafeRootLeafNode, node.firstChild)
+			index := int(node.firstChild)
+			var i uint8
+			for i = node.nChildren; i>0; i-- {
+				px := *(*float64)(unsafe.Pointer(f))
+				f = f + float_size
+				py := *(*float64)(unsafe.Pointer(f))
+
+				d := computeLeafDistance(px, py, x, y)
+				if d <= dsquared && (deleted == nil || !deleted[index]) {
+					sq = append(sq, searchQueueItem{node: uintptr(unsafe.Pointer(nil)), index: index, distance: d})
+				}
+				f = f + float_size
+				index++
+			}
+		default:
+			f := nodeAddress(unsafeRootNode, node.firstChild)
+			var i uint8
+			for i = node.nChildren; i>0; i-- {
+				n := (*rNode)(unsafe.Pointer(f))
+				mind, _ := vectorComputeDistances(n.BBox, x, y)
+				// maxd cannot be used here, it only guarantees one point within that distance
+				if mind <= dsquared {
+					sq = append(sq, searchQueueItem{node: uintptr(unsafe.Pointer(n)), distance: mind})
+				}
+				f = f + node_size
+			}
+		}
+	}
+
+	r.putQueue(sq)
+}
+
+// search calls visit with every point within the bbox
+func (r *SimpleRTree) search(bbox rBBox, visit func (index int)) {
+	if len(r.nodes) == 0 {
+		return
+	}
+	// There is no need to visit the nodes in any order, so the queue is used as a stack
+	sq := r.getQueue()
+
+	rootNode := &r.nodes[0]
+	unsafeRootLeafNode := uintptr(unsafe.Pointer(&r.points[0]))
+	unsafeRootNode := uintptr(unsafe.Pointer(rootNode))
+	sq = append(sq, searchQueueItem{node: uintptr(unsafe.Pointer(rootNode))}) // root bbox is not checked, every query starts at the root
+	deleted := r.deleted
+
+	for sq.Len() > 0 {
+		item := sq[sq.Len() - 1]
+		sq = sq[0: sq.Len() - 1]
+
+		node := (*rNode)(unsafe.Pointer(item.node))
+		switch node.nodeType {
+		case preleaf_node:
+			f := pointAddress(unsafeRootLeafNode, node.firstChild)
+			index := int(node.firstChild)
+			var i uint8
+			for i = node.nChildren; i>0; i-- {
+				px := *(*float64)(unsafe.Pointer(f))
+				f = f + float_size
+				py := *(*float64)(unsafe.Pointer(f))
+				if bbox.containsPoint(px, py) && (deleted == nil || !deleted[index]) {
+					visit(index)
+				}
+				f = f + float_size
+				index++
+			}
+		default:
+			f := nodeAddress(unsafeRootNode, node.firstChild)
+			var i uint8
+			for i = node.nChildren; i>0; i-- {
+				n := (*rNode)(unsafe.Pointer(f))
+				if bbox.intersects(n.BBox.toBBox()) {
+					sq = append(sq, searchQueueItem{node: uintptr(unsafe.Pointer(n))})
+				}
+				f = f + node_size
+			}
+		}
+	}
+
+	r.putQueue(sq)
+}
+
+// findPointsWithin calls visit with every point within the distance squared dsquared
+func (r *SimpleRTree) findPointsWithin(x, y, dsquared float64, visit func (index int)) {
+	if len(r.nodes) == 0 {
+		return
+	}
+	if m := r.options.metric(); m != nil {
+		r.findPointsWithinMetric(m, x, y, dsquared, visit)
+		return
+	}
+	// nodes are traversed as in search
+	sq := r.getQueue()
+
+	rootNode := &r.nodes[0]
+	unsafeRootLeafNode := uintptr(unsafe.Pointer(&r.points[0]))
+	unsafeRootNode := uintptr(unsafe.Pointer(rootNode))
+	sq = append(sq, searchQueueItem{node: uintptr(unsafe.Pointer(rootNode))})
+	deleted := r.deleted
+
+	for sq.Len() > 0 {
+		item := sq[sq.Len() - 1]
+		sq = sq[0: sq.Len() - 1]
+
+		node := (*rNode)(unsafe.Pointer(item.node))
+		switch node.nodeType {
+		case preleaf_node:
+			f := pointAddress(unsafeRootLeafNode, node.firstChild)
+			index := int(node.firstChild)
+			var i uint8
+			for i = node.nChildren; i>0; i-- {
+				px := *(*float64)(unsafe.Pointer(f))
+				f = f + float_size
+				py := *(*float64)(unsafe.Pointer(f))
+				if computeLeafDistance(px, py, x, y) <= dsquared && (deleted == nil || !deleted[index]) {
+					visit(index)
+				}
+				f = f + float_size
+				index++
+			}
+		default:
+			f := nodeAddress(unsafeRootNode, node.firstChild)
+			var i uint8
+			for i = node.nChildren; i>0; i-- {
+				n := (*rNode)(unsafe.Pointer(f))
+				mind, _ := vectorComputeDistances(n.BBox, x, y)
+				if mind <= dsquared {
+					sq = append(sq, searchQueueItem{node: uintptr(unsafe.Pointer(n))})
+				}
+				f = f + node_size
+			}
+		}
+	}
+
+	r.putQueue(sq)
+}
+
+// getQueue returns a search queue for the query. In unsafe mode it is shared by all queries
+func (r *SimpleRTree) getQueue() searchQueue {
+	if r.options.UnsafeConcurrencyMode {
+		return r.unsafeQueue[0:0]
+	}
+	return r.queuePool.Get().(searchQueue)[0:0]
+}
+
+// putQueue gives back the queue, it might have grown during the query so we keep the new one
+func (r *SimpleRTree) putQueue(sq searchQueue) {
 	if !r.options.UnsafeConcurrencyMode {
 		r.queuePool.Put(sq)
 	} else {
 		r.unsafeQueue = sq
 	}
+}
 
-	if !found {
-		return
+func (r *SimpleRTree) load(points FlatPoints, ids []int, isSorted bool) *SimpleRTree {
+	if err := r.loadErr(points, ids, isSorted); err != nil {
+		log.Fatal(err)
 	}
-	x1 = minItem.px
-	y1 = minItem.py
-	d1 = distanceUpperBound
-	return
+	return r
 }
 
-func (r *SimpleRTree) load(points FlatPoints, isSorted bool) *SimpleRTree {
+func (r *SimpleRTree) loadErr(points FlatPoints, ids []int, isSorted bool) error {
+	if r.built {
+		return ErrAlreadyBuilt
+	}
+	if ids != nil && len(ids) != points.Len() {
+		return fmt.Errorf("%w: %d ids for %d points", ErrIDsLength, len(ids), points.Len())
+	}
 	if points.Len() == 0 {
-		return r
+		return nil
 	}
-	if points.Len() >= math.MaxUint32 / int(node_size) {
-		log.Fatal("Exceded maximum possible size", math.MaxUint32 / int(node_size))
+	if err := checkSize(points.Len()); err != nil {
+		return err
 	}
 	if r.options.MAX_ENTRIES == 0 {
-		panic("MAX entries was 0")
-	}
-	if r.built {
-		log.Fatal("Tree is static, cannot load twice")
+		return fmt.Errorf("%w: MAX_ENTRIES was 0", ErrInvalidOptions)
 	}
 	r.built = true
 
@@ -325,7 +683,26 @@ func (r *SimpleRTree) load(points FlatPoints, isSorted bool) *SimpleRTree {
 	} else {
 		r.sorterBuffer = make([]int, 0, r.options.MAX_ENTRIES+1)
 	}
-	r.points = points
+	if r.options.CopyPoints {
+		if isPooledMemReceived && cap(rtreePooledMem.points) >= len(points) {
+			r.points = rtreePooledMem.points[0: len(points)]
+		} else {
+			r.points = make(FlatPoints, len(points))
+		}
+		copy(r.points, points)
+		points = r.points
+		if ids != nil {
+			if isPooledMemReceived && cap(rtreePooledMem.ids) >= len(ids) {
+				r.ids = rtreePooledMem.ids[0: len(ids)]
+			} else {
+				r.ids = make([]int, len(ids))
+			}
+			copy(r.ids, ids)
+		}
+	} else {
+		r.points = points
+		r.ids = ids
+	}
 	if isPooledMemReceived && cap(rtreePooledMem.nodes) >= computeSize(points.Len()) {
 		r.nodes = rtreePooledMem.nodes[0: 0]
 	} else {
@@ -338,28 +715,35 @@ func (r *SimpleRTree) load(points FlatPoints, isSorted bool) *SimpleRTree {
 		rootNodeConstruct = r.buildHilbert(points, isSorted)
 	}
 
-	if isPooledMemReceived && r.options.UnsafeConcurrencyMode && cap(rtreePooledMem.sq) >= rootNodeConstruct.height*r.options.MAX_ENTRIES {
+	r.height = rootNodeConstruct.height
+	r.initQueues(rtreePooledMem) // nil if no memory was received
+	return nil
+}
+
+// initQueues creates the search queues once the height of the tree is known. The queue of rtreePooledMem is reused if possible
+func (r *SimpleRTree) initQueues(rtreePooledMem *pooledMem) {
+	size := r.height*r.options.MAX_ENTRIES
+	if rtreePooledMem != nil && r.options.UnsafeConcurrencyMode && cap(rtreePooledMem.sq) >= size {
 		r.unsafeQueue = rtreePooledMem.sq
 	} else {
 		if r.options.UnsafeConcurrencyMode {
-			r.unsafeQueue = make(searchQueue, rootNodeConstruct.height*r.options.MAX_ENTRIES)
+			r.unsafeQueue = make(searchQueue, size)
 		} else {
 			r.queuePool = sync.Pool{
 				New: func () interface {} {
-					return make(searchQueue, rootNodeConstruct.height*r.options.MAX_ENTRIES)
+					return make(searchQueue, size)
 				},
 			}
 			firstQueue := r.queuePool.Get()
 			r.queuePool.Put(firstQueue)
 		}
 	}
-	return r
 }
 
 func (r *SimpleRTree) buildHilbert(points FlatPoints, isSorted bool) nodeConstruct {
 	r.nodes = append(r.nodes, rNode{})
 	if (!isSorted) {
-		r.sortHilbert(points)
+		r.sortHilbert(points, r.ids)
 	}
 
 	nBuckets := points.Len() / r.options.MAX_ENTRIES
@@ -388,8 +772,8 @@ func (r *SimpleRTree) buildHilbert(points FlatPoints, isSorted bool) nodeConstru
 		r.nodes = append(r.nodes, rNode{
 			nodeType: preleaf_node,
 			BBox: vb,
-			nChildren: int8(end - start),
-			firstChildOffset: uint32(start) * uint32(flat_point_size),
+			nChildren: uint8(end - start),
+			firstChild: uint32(start),
 		})
 	}
 	for nBuckets > r.options.MAX_ENTRIES {
@@ -403,7 +787,8 @@ func (r *SimpleRTree) buildHilbert(points FlatPoints, isSorted bool) nodeConstru
 		}
 		for i:= 0; i < nBuckets ; i++ {
 			start := previousStart + i * r.options.MAX_ENTRIES
-			end := minInt(start + r.options.MAX_ENTRIES, len(r.nodes))
+			// nodes of the current level are being appended, so we cannot bound by len(r.nodes)
+			end := minInt(start + r.options.MAX_ENTRIES, previousStart + previousNBuckets)
 			vb := r.nodes[start].BBox
 
 			for i := end - start - 1; i > 0; i-- {
@@ -413,17 +798,22 @@ func (r *SimpleRTree) buildHilbert(points FlatPoints, isSorted bool) nodeConstru
 			r.nodes = append(r.nodes, rNode{
 				nodeType: default_node,
 				BBox: vb,
-				nChildren: int8(end - start),
-				firstChildOffset: uint32(start) * uint32(node_size),
+				nChildren: uint8(end - start),
+				firstChild: uint32(start),
 			})
 		}
 	}
 	previousStart = nextStart
+	// queries do not check the bbox of the root, but exporters draw it
+	vb := r.nodes[previousStart].BBox
+	for i := previousStart + 1; i < previousStart + nBuckets; i++ {
+		vb = vectorBBoxExtend(vb, r.nodes[i].BBox)
+	}
 	r.nodes[0] = rNode{
 		nodeType: default_node,
-		// no need for bbox
-		firstChildOffset: uint32(previousStart)  * uint32(node_size),
-		nChildren: int8(nBuckets),
+		BBox: vb,
+		firstChild: uint32(previousStart),
+		nChildren: uint8(nBuckets),
 	}
 
 	return nodeConstruct{
@@ -431,19 +821,40 @@ func (r *SimpleRTree) buildHilbert(points FlatPoints, isSorted bool) nodeConstru
 	}
 }
 
-func (r *SimpleRTree) sortHilbert(points FlatPoints) {
+// sortHilbert sorts points along the curve of the tree, ids can be nil
+func (r *SimpleRTree) sortHilbert(points FlatPoints, ids []int) {
+	curve := hilbertIndex
+	if r.options.TreeType == ZORDER {
+		curve = interleave
+	}
+	grid := r.curveGrid(points)
 	hashes := make([]uint64, points.Len())
 	for i:= 0; i < points.Len(); i++ {
-		hash := GeoHash(points.GetPointAt(i))
-		hashes[i] = hash
+		hashes[i] = curve(grid.quantize(points.GetPointAt(i)))
 	}
 	sorter := GeoHashSorter{
 		points: points,
+		ids: ids,
 		hashes: hashes,
 	}
 	sort.Sort(sorter)
 }
 
+// curveGrid quantizes against Options.WorldBBox if given, otherwise against the extent of the points
+func (r *SimpleRTree) curveGrid(points FlatPoints) curveGrid {
+	if r.options.WorldBBox != nil {
+		b := r.options.WorldBBox
+		return newCurveGrid(b[0], b[1], b[2], b[3])
+	}
+	x0, y0 := points.GetPointAt(0)
+	vb := rVectorBBox{x0, y0, x0, y0}
+	for i := 1; i < points.Len(); i++ {
+		x1, y1 := points.GetPointAt(i)
+		vb = vectorBBoxExtend(vb, rVectorBBox{x1, y1, x1, y1})
+	}
+	return newCurveGrid(vb[0], vb[1], vb[2], vb[3])
+}
+
 func (r *SimpleRTree) buildSTR(points FlatPoints, isSorted bool) nodeConstruct {
 	r.nodes = append(r.nodes, rNode{})
 	rootNodeConstruct := nodeConstruct{
@@ -452,16 +863,17 @@ func (r *SimpleRTree) buildSTR(points FlatPoints, isSorted bool) nodeConstruct {
 		end:    uint32(points.Len()),
 	}
 
-	r.buildNodeDownwards(&r.nodes[0], rootNodeConstruct, isSorted)
+	r.buildNodeDownwards(0, rootNodeConstruct, isSorted)
 	return rootNodeConstruct
 }
 
-func (r *SimpleRTree) buildNodeDownwards(n *rNode, nc nodeConstruct, isSorted bool) rVectorBBox {
+// buildNodeDownwards builds the node at nodeIndex. Nodes are referenced by index since r.nodes might grow while building children
+func (r *SimpleRTree) buildNodeDownwards(nodeIndex int, nc nodeConstruct, isSorted bool) rVectorBBox {
 	N := int(nc.end - nc.start)
 	// target number of root entries to maximize storage utilization
 	var M float64
 	if N <= r.options.MAX_ENTRIES { // Leaf node
-		return r.setLeafNode(n, nc)
+		return r.setLeafNode(&r.nodes[nodeIndex], nc)
 	}
 
 	M = math.Ceil(float64(N) / float64(math.Pow(float64(r.options.MAX_ENTRIES), float64(nc.height-1))))
@@ -472,15 +884,15 @@ func (r *SimpleRTree) buildNodeDownwards(n *rNode, nc nodeConstruct, isSorted bo
 	start := int(nc.start)
 	// parent node might already be sorted. In that case we avoid double computation
 	if !isSorted {
-		sortX := xSorter{n: n, points: r.points, start: start, end: int(nc.end), bucketSize: N1}
+		sortX := xSorter{n: &r.nodes[nodeIndex], points: r.points, ids: r.ids, start: start, end: int(nc.end), bucketSize: N1}
 		sortX.Sort(r.sorterBuffer)
 	}
 	nodeConstructs := [MAX_POSSIBLE_SIZE]nodeConstruct{}
-	var nodeConstructIndex int8
+	var nodeConstructIndex uint8
 	firstChildIndex := len(r.nodes)
 	for i := 0; i < N; i += N1 {
 		right2 := minInt(i+N1, N)
-		sortY := ySorter{n: n, points: r.points, start: start+ i, end: start+ right2, bucketSize: N2}
+		sortY := ySorter{n: &r.nodes[nodeIndex], points: r.points, ids: r.ids, start: start+ i, end: start+ right2, bucketSize: N2}
 		sortY.Sort(r.sorterBuffer)
 		for j := i; j < right2; j += N2 {
 			right3 := minInt(j+N2, right2)
@@ -495,17 +907,18 @@ func (r *SimpleRTree) buildNodeDownwards(n *rNode, nc nodeConstruct, isSorted bo
 			nodeConstructIndex++
 		}
 	}
-	n.firstChildOffset = uint32(firstChildIndex) * uint32(node_size)
+	n := &r.nodes[nodeIndex]
+	n.firstChild = uint32(firstChildIndex)
 	n.nChildren = nodeConstructIndex
 	// compute children
-	var i int8
-	bbox := r.buildNodeDownwards(&r.nodes[firstChildIndex], nodeConstructs[i], false)
+	var i uint8
+	bbox := r.buildNodeDownwards(firstChildIndex, nodeConstructs[i], false)
 	for i = 1; i < nodeConstructIndex; i++ {
 		// TODO check why using (*Node)f here does not work
-		bbox2 := r.buildNodeDownwards(&r.nodes[firstChildIndex+int(i)], nodeConstructs[i], false)
+		bbox2 := r.buildNodeDownwards(firstChildIndex+int(i), nodeConstructs[i], false)
 		bbox = vectorBBoxExtend(bbox, bbox2)
 	}
-	n.BBox = bbox
+	r.nodes[nodeIndex].BBox = bbox
 	return bbox
 }
 
@@ -528,68 +941,13 @@ func (r *SimpleRTree) setLeafNode(n *rNode, nc nodeConstruct) rVectorBBox {
 		}
 		vb = vectorBBoxExtend(vb, vb1)
 	}
-	n.firstChildOffset = uint32(firstChildIndex) * uint32(flat_point_size) // We access leafs on the original array
-	n.nChildren = int8(nc.end - nc.start)
+	n.firstChild = uint32(firstChildIndex) // We access leafs on the original array
+	n.nChildren = uint8(nc.end - nc.start)
 	n.nodeType = preleaf_node
 	n.BBox = vb
 	return vb
 }
 
-func (r *SimpleRTree) toJSON() {
-	text := make([]string, 0)
-	fmt.Println(strings.Join(r.toJSONAcc(&r.nodes[0], text), ","))
-}
-
-func (r *SimpleRTree) toJSONAcc(n *rNode, text []string) []string {
-	t, err := template.New("foo").Parse(`{
-	       "type": "Feature",
-	       "properties": {},
-	       "geometry": {
-       "type": "Polygon",
-       "coordinates": [
-       [
-       [
-       {{index .BBox 0}},
-       {{index .BBox 1}}
-       ],
-       [
-       {{index .BBox 2}},
-       {{index .BBox 1}}
-       ],
-       [
-       {{index .BBox 2}},
-       {{index .BBox 3}}
-       ],
-       [
-       {{index .BBox 0}},
-       {{index .BBox 3}}
-       ],
-       [
-       {{index .BBox 0}},
-       {{index .BBox 1}}
-       ]
-       ]
-       ]
-       }
-       }`)
-	if err != nil {
-		log.Fatal(err)
-	}
-	var tpl bytes.Buffer
-	if err := t.Execute(&tpl, n); err != nil {
-		log.Fatal(err)
-	}
-	text = append(text, tpl.String())
-	f := unsafe.Pointer(uintptr(unsafe.Pointer(&r.nodes[0])) + uintptr(n.firstChildOffset))
-	var i int8
-	for i = 0; i < n.nChildren; i++ {
-		cn := (*rNode)(f)
-		text = r.toJSONAcc(cn, text)
-		f = unsafe.Pointer(uintptr(f) + node_size)
-	}
-	return text
-}
-
 // node is point, there is only one distance
 func computeLeafDistance(px, py, x, y float64) float64 {
 	return (x-px)*(x-px) +
@@ -634,7 +992,6 @@ func computeDistances(bbox rVectorBBox, x, y float64) (mind, maxd float64) {
 			mind = minx + miny
 		}*/
 }
-func vectorComputeDistances(bbox rVectorBBox, x, y float64) (mind, maxd float64)
 
 func minInt(a, b int) int {
 	if a < b {
@@ -668,6 +1025,18 @@ func (fp FlatPoints) GetPointAt(i int) (x1, y1 float64) {
 	return fp[2*i], fp[2*i+1]
 }
 
+// SortByDistance sorts the points in increasing order of euclidean distance to the coordinates x and y.
+// Results of trees with Options.Geodesic or Options.Metric must be sorted with SimpleRTree.SortByDistance instead
+func (fp FlatPoints) SortByDistance(x, y float64) {
+	sort.Sort(distanceSorter{points: fp, x: x, y: y})
+}
+
+// SortByDistance sorts the points in increasing order of distance to the coordinates x and y,
+// measured as the queries of the tree do, that is with Options.Geodesic or Options.Metric if set
+func (r *SimpleRTree) SortByDistance(x, y float64, points FlatPoints) {
+	sort.Sort(distanceSorter{points: points, x: x, y: y, metric: r.options.metric()})
+}
+
 func sortFloats(x1, x2 float64) (x3, x4 float64) {
 	if x1 > x2 {
 		return x2, x1
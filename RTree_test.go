@@ -8,6 +8,9 @@ import (
 	"testing"
 	"sync"
 	"fmt"
+	"sort"
+	"errors"
+	"unsafe"
 )
 
 func TestNode_ComputeDistances(t *testing.T) {
@@ -64,10 +67,16 @@ func TestNode_ComputeDistances(t *testing.T) {
 			17,
 		},
 	}
-	for _, n := range ns {
-		mind, maxd := vectorComputeDistances(n.BBox, 5, 5)
-		assert.Equal(t, n.mind, mind)
-		assert.Equal(t, n.maxd, maxd)
+	implementations := map[string]func(rVectorBBox, float64, float64) (float64, float64){
+		"go":     computeDistances,
+		"vector": vectorComputeDistances,
+	}
+	for name, computeDistancesImpl := range implementations {
+		for _, n := range ns {
+			mind, maxd := computeDistancesImpl(n.BBox, 5, 5)
+			assert.Equal(t, n.mind, mind, name)
+			assert.Equal(t, n.maxd, maxd, name)
+		}
 	}
 
 }
@@ -86,15 +95,24 @@ func TestSimpleRTree_FindNearestPoint(t *testing.T) {
 			TreeType: HILBERT,
 		},
 	).Load(fp2)
+	fp3 := FlatPoints(append(make([]float64, 0, len(points)), points...))
+	r3 := NewWithOptions(
+		Options{
+			TreeType: ZORDER,
+		},
+	).Load(fp3)
 	for i := 0; i < 1000; i++ {
 		x, y := rand.Float64(), rand.Float64()
 		x1, y1, _ := r.FindNearestPoint(x, y)
 		x2, y2, _ := fp.linearClosestPoint(x, y)
 		x3, y3, _ := r2.FindNearestPoint(x, y)
+		x4, y4, _ := r3.FindNearestPoint(x, y)
 		assert.Equal(t, x1, x2)
 		assert.Equal(t, y1, y2)
 		assert.Equal(t, x1, x3)
 		assert.Equal(t, y1, y3)
+		assert.Equal(t, x1, x4)
+		assert.Equal(t, y1, y4)
 	}
 }
 
@@ -179,6 +197,191 @@ func TestSimpleRTree_FindNearestPointBigUnsafeMode(t *testing.T) {
 
 }
 
+func TestSimpleRTree_FindKNearestPoints(t *testing.T) {
+	const size = 2000
+	const k = 10
+	points := make([]float64, size*2)
+	for i := 0; i < 2*size; i++ {
+		points[i] = rand.Float64()
+	}
+	fp := FlatPoints(points)
+	r := New().Load(fp)
+	fp2 := FlatPoints(append(make([]float64, 0, len(points)), points...))
+	rU := NewWithOptions(Options{UnsafeConcurrencyMode: true, TreeType: HILBERT}).Load(fp2)
+	dst := make(FlatPoints, 0, 2*k)
+	dstU := make(FlatPoints, 0, 2*k)
+	for i := 0; i < 200; i++ {
+		x, y := rand.Float64(), rand.Float64()
+		dst = r.FindKNearestPoints(x, y, k, dst[0:0])
+		dstU = rU.FindKNearestPoints(x, y, k, dstU[0:0])
+		expected := fp.linearKClosestPoints(x, y, k)
+		assert.Equal(t, expected, dst)
+		assert.Equal(t, expected, dstU)
+		x1, y1, _ := r.FindNearestPoint(x, y)
+		assert.Equal(t, x1, dst[0])
+		assert.Equal(t, y1, dst[1])
+	}
+}
+
+func TestSimpleRTree_FindKNearestPointsWithin(t *testing.T) {
+	points := []float64{0.0, 0.0, 1.0, 0.0, 1.0, 1.0, 0.0, 1.0, 3.0, 3.0}
+	r := New().Load(FlatPoints(points))
+	result := r.FindKNearestPointsWithin(0.1, 0.1, 1, 3, nil)
+	assert.Equal(t, FlatPoints{0.0, 0.0}, result[0:2])
+	assert.Equal(t, 6, len(result), "Three points within distance")
+	result = r.FindKNearestPointsWithin(5, 5, 1, 3, nil)
+	assert.Equal(t, 0, len(result), "No point within distance")
+	result = r.FindKNearestPoints(0, 0, 10, nil)
+	assert.Equal(t, 10, len(result), "All points are returned if k is bigger than the tree")
+	assert.Equal(t, FlatPoints{3.0, 3.0}, result[8:10])
+}
+
+func TestSimpleRTree_Search(t *testing.T) {
+	const size = 20000
+	points := make([]float64, size*2)
+	for i := 0; i < 2*size; i++ {
+		points[i] = rand.Float64()
+	}
+	fp := FlatPoints(points)
+	r := New().Load(fp)
+	fp2 := FlatPoints(append(make([]float64, 0, len(points)), points...))
+	rH := NewWithOptions(Options{TreeType: HILBERT}).Load(fp2)
+	var dst, dstH FlatPoints
+	for i := 0; i < 100; i++ {
+		x1, x2 := sortFloats(rand.Float64(), rand.Float64())
+		y1, y2 := sortFloats(rand.Float64(), rand.Float64())
+		expected := fp.linearSearch(x1, y1, x2, y2)
+		dst = r.Search(x1, y1, x2, y2, dst[0:0])
+		dstH = rH.Search(x1, y1, x2, y2, dstH[0:0])
+		assert.Equal(t, expected, dst.sorted())
+		assert.Equal(t, expected, dstH.sorted())
+	}
+}
+
+func TestSimpleRTree_SearchBorders(t *testing.T) {
+	points := []float64{0.0, 0.0, 1.0, 0.0, 1.0, 1.0, 0.0, 1.0, 3.0, 3.0}
+	r := New().Load(FlatPoints(points))
+	assert.Equal(t, 8, len(r.Search(0, 0, 1, 1, nil)), "Points on the border are included")
+	assert.Equal(t, FlatPoints{3.0, 3.0}, r.Search(2, 2, 3, 5, nil))
+	assert.Equal(t, 0, len(r.Search(4, 4, 5, 5, nil)))
+}
+
+func TestSimpleRTree_FindPointsWithin(t *testing.T) {
+	const size = 20000
+	points := make([]float64, size*2)
+	for i := 0; i < 2*size; i++ {
+		points[i] = rand.Float64()
+	}
+	fp := FlatPoints(points)
+	r := New().Load(fp)
+	fp2 := FlatPoints(append(make([]float64, 0, len(points)), points...))
+	rU := NewWithOptions(Options{UnsafeConcurrencyMode: true, TreeType: HILBERT}).Load(fp2)
+	var dst, dstU FlatPoints
+	for i := 0; i < 100; i++ {
+		x, y := rand.Float64(), rand.Float64()
+		d := rand.Float64() * 0.01
+		expected := fp.linearPointsWithin(x, y, d)
+		dst = r.FindPointsWithin(x, y, d, dst[0:0])
+		dstU = rU.FindPointsWithin(x, y, d, dstU[0:0])
+		assert.Equal(t, expected, dst.sorted())
+		assert.Equal(t, expected, dstU.sorted())
+		dst.SortByDistance(x, y)
+		kNearest := r.FindKNearestPoints(x, y, dst.Len(), FlatPoints{})
+		assert.Equal(t, kNearest, append(FlatPoints{}, dst...), "Sorted points are the k nearest")
+	}
+}
+
+func TestSimpleRTree_LoadCopyPoints(t *testing.T) {
+	const size = 2000
+	points := make([]float64, size*2)
+	for i := 0; i < 2*size; i++ {
+		points[i] = rand.Float64()
+	}
+	original := append(make([]float64, 0, len(points)), points...)
+	pool := &sync.Pool{}
+	for _, treeType := range []TreeType{STR, HILBERT} {
+		r := NewWithOptions(Options{CopyPoints: true, RTreePool: pool, TreeType: treeType}).LoadWithIDs(FlatPoints(points), nil)
+		assert.Equal(t, original, points, "Points are not modified")
+		for i := 0; i < 100; i++ {
+			x, y := rand.Float64(), rand.Float64()
+			id, x1, y1, _ := r.FindNearestPointID(x, y)
+			x2, y2, _ := FlatPoints(points).linearClosestPoint(x, y)
+			assert.Equal(t, x2, x1)
+			assert.Equal(t, y2, y1)
+			assert.Equal(t, x2, points[2 * id])
+			assert.Equal(t, y2, points[2 * id + 1])
+		}
+		r.Destroy()
+	}
+	// Copy is taken from the pool
+	r := NewWithOptions(Options{CopyPoints: true, RTreePool: pool}).Load(FlatPoints(points))
+	r.Destroy()
+	mem := pool.Get().(*pooledMem)
+	assert.Equal(t, len(points), len(mem.points))
+	pool.Put(mem)
+	assert.Equal(t, original, points, "Points are not modified")
+}
+
+func TestSimpleRTree_CurveTreesProjectedCoordinates(t *testing.T) {
+	// Coordinates in metres, far away from the lat lng range
+	const size = 2000
+	points := make([]float64, size*2)
+	for i := 0; i < size; i++ {
+		points[2*i] = 4e5 + rand.Float64()*1e6
+		points[2*i+1] = 4e6 + rand.Float64()*1e6
+	}
+	fp := FlatPoints(points)
+	extent := 1e6 * 1e6
+	for _, options := range []Options{
+		{TreeType: HILBERT},
+		{TreeType: ZORDER},
+		{TreeType: HILBERT, WorldBBox: &[4]float64{0, 0, 1e7, 1e7}},
+	} {
+		r := NewWithOptions(options).Load(append(FlatPoints{}, points...))
+		// Leaves of a well packed tree barely overlap, with a saturated grid they would span the whole extent
+		leavesArea := 0.
+		for _, n := range r.nodes {
+			if n.nodeType == preleaf_node {
+				leavesArea += (n.BBox[2] - n.BBox[0]) * (n.BBox[3] - n.BBox[1])
+			}
+		}
+		assert.True(t, leavesArea < 10*extent, "Leaves cover %f times the extent", leavesArea/extent)
+		for i := 0; i < 100; i++ {
+			x, y := 4e5+rand.Float64()*1e6, 4e6+rand.Float64()*1e6
+			x1, y1, _ := r.FindNearestPoint(x, y)
+			x2, y2, _ := fp.linearClosestPoint(x, y)
+			assert.Equal(t, x2, x1)
+			assert.Equal(t, y2, y1)
+		}
+	}
+}
+
+func TestSimpleRTree_FanOut(t *testing.T) {
+	const size = 2000
+	points := make([]float64, size*2)
+	for i := 0; i < 2*size; i++ {
+		points[i] = rand.Float64()
+	}
+	fp := FlatPoints(points)
+	for _, treeType := range []TreeType{STR, HILBERT} {
+		for _, maxEntries := range []int{2, 4, 16, 32, 64} {
+			r := NewWithOptions(Options{TreeType: treeType, MAX_ENTRIES: maxEntries, CopyPoints: true}).Load(fp)
+			for _, n := range r.nodes[1:] {
+				assert.True(t, int(n.nChildren) <= maxEntries)
+			}
+			for i := 0; i < 50; i++ {
+				x, y := rand.Float64(), rand.Float64()
+				x1, y1, _ := r.FindNearestPoint(x, y)
+				x2, y2, _ := fp.linearClosestPoint(x, y)
+				assert.Equal(t, x2, x1)
+				assert.Equal(t, y2, y1)
+				assert.Equal(t, fp.linearKClosestPoints(x, y, 10), r.FindKNearestPoints(x, y, 10, nil))
+				assert.Equal(t, fp.linearSearch(x, y, x+0.05, y+0.05), r.Search(x, y, x+0.05, y+0.05, FlatPoints{}).sorted())
+			}
+		}
+	}
+}
+
 func TestComputeSize(t *testing.T) {
 	testCases := []struct {
 		len      int
@@ -203,6 +406,55 @@ func TestComputeSize(t *testing.T) {
 	}
 }
 
+func TestSimpleRTree_HilbertNodesHaveOneParent(t *testing.T) {
+	const size = 2000 // enough for several levels of default nodes
+	points := make([]float64, size*2)
+	for i := 0; i < 2*size; i++ {
+		points[i] = rand.Float64()
+	}
+	r := NewWithOptions(Options{TreeType: HILBERT}).Load(FlatPoints(points))
+	children := 0
+	for _, n := range r.nodes {
+		if n.nodeType == default_node {
+			children += int(n.nChildren)
+		}
+	}
+	assert.Equal(t, len(r.nodes) - 1, children, "Every node but the root is the child of exactly one node")
+}
+
+func TestLargeIndexes(t *testing.T) {
+	if unsafe.Sizeof(uintptr(0)) < 8 {
+		t.Skip("offsets beyond 32 bits need a 64 bit platform")
+	}
+	// Addresses are never dereferenced, so the whole dataset is not needed
+	const base = uintptr(1 << 12)
+	testCases := []struct {
+		index uint32
+	}{
+		{0},
+		{math.MaxUint32 / 40},
+		{math.MaxUint32/40 + 1},
+		{1 << 31},
+		{math.MaxUint32 / 2},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, uint64(base)+uint64(tc.index)*40, uint64(nodeAddress(base, tc.index)))
+		assert.Equal(t, uint64(base)+uint64(tc.index)*16, uint64(pointAddress(base, tc.index)))
+	}
+	// first indexes whose byte offset does not fit in 32 bits
+	farNode := uint32(math.MaxUint32/node_size) + 1
+	farPoint := uint32(math.MaxUint32/flat_point_size) + 1
+	assert.True(t, nodeAddress(base, farNode)-base > math.MaxUint32, "Offset overflows 32 bits")
+	assert.True(t, pointAddress(base, farPoint)-base > math.MaxUint32, "Offset overflows 32 bits")
+	assert.Equal(t, node_size, nodeAddress(base, farNode+1)-nodeAddress(base, farNode))
+	assert.Equal(t, flat_point_size, pointAddress(base, farPoint+1)-pointAddress(base, farPoint))
+
+	assert.NoError(t, checkSize(200000000))
+	n := maxPoints
+	assert.NoError(t, checkSize(n))
+	assert.True(t, errors.Is(checkSize(n+1), ErrTooManyPoints))
+}
+
 func Benchmark_ComputeDistances(b *testing.B) {
 	size := 1000000
 	points := make([]float64, size+10)
@@ -266,6 +518,49 @@ func BenchmarkSimpleRTree_Load(b *testing.B) {
 		})
 	}
 }
+
+var treeTypeNames = map[TreeType]string{STR: "STR", HILBERT: "HILBERT", ZORDER: "ZORDER"}
+
+func BenchmarkSimpleRTree_LoadFanOut(b *testing.B) {
+	const size = 1000000
+	points := make([]float64, size*2)
+	for i := 0; i < 2*size; i++ {
+		points[i] = rand.Float64()
+	}
+	fp := make(FlatPoints, len(points))
+	for _, treeType := range []TreeType{STR, HILBERT} {
+		for _, maxEntries := range []int{4, 9, 16, 32, 64} {
+			b.Run(fmt.Sprintf("%s-%d", treeTypeNames[treeType], maxEntries), func(b *testing.B) {
+				for n := 0; n < b.N; n++ {
+					b.StopTimer()
+					copy(fp, points)
+					b.StartTimer()
+					_ = NewWithOptions(Options{TreeType: treeType, MAX_ENTRIES: maxEntries}).Load(fp)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkSimpleRTree_FindNearestPointFanOut(b *testing.B) {
+	const size = 1000000
+	points := make([]float64, size*2)
+	for i := 0; i < 2*size; i++ {
+		points[i] = rand.Float64()
+	}
+	for _, treeType := range []TreeType{STR, HILBERT} {
+		for _, maxEntries := range []int{4, 9, 16, 32, 64} {
+			b.Run(fmt.Sprintf("%s-%d", treeTypeNames[treeType], maxEntries), func(b *testing.B) {
+				r := NewWithOptions(Options{TreeType: treeType, MAX_ENTRIES: maxEntries, UnsafeConcurrencyMode: true, CopyPoints: true}).Load(FlatPoints(points))
+				b.ResetTimer()
+				for n := 0; n < b.N; n++ {
+					_, _, _ = r.FindNearestPoint(rand.Float64(), rand.Float64())
+				}
+			})
+		}
+	}
+}
+
 func BenchmarkSimpleRTree_LoadPooled(b *testing.B) {
 	benchmarks := []struct {
 		name string
@@ -364,6 +659,40 @@ func BenchmarkSimpleRTree_FindNearestPointHilbert(b *testing.B) {
 }
 
 
+func BenchmarkSimpleRTree_FindNearestPointZOrder(b *testing.B) {
+	benchmarks := []struct {
+		name string
+		size int
+	}{
+		{"10", 10},
+		{"1000", 1000},
+		{"10000", 10000},
+		{"100000", 100000},
+		{"200000", 200000},
+		{"1000000", 1000000},
+		{"10000000", 10000000},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			size := bm.size
+			points := make([]float64, size*2)
+			for i := 0; i < 2*size; i++ {
+				points[i] = rand.Float64()
+			}
+			fp := FlatPoints(points)
+			r := NewWithOptions(Options{
+				TreeType: ZORDER,
+				UnsafeConcurrencyMode: true,
+			}).Load(fp)
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				x, y := rand.Float64(), rand.Float64()
+				_, _, _ = r.FindNearestPoint(x, y)
+			}
+		})
+	}
+}
+
 func BenchmarkSimpleRTree_FindNearestPointMemory(b *testing.B) {
 	benchmarks := []struct {
 		name string
@@ -449,6 +778,56 @@ func (fp FlatPoints) linearClosestPoint(x, y float64) (x1, y1, d float64) {
 	return
 }
 
+func (fp FlatPoints) linearSearch(minX, minY, maxX, maxY float64) FlatPoints {
+	result := FlatPoints{}
+	for i := 0; i < fp.Len(); i++ {
+		x, y := fp.GetPointAt(i)
+		if minX <= x && x <= maxX && minY <= y && y <= maxY {
+			result = append(result, x, y)
+		}
+	}
+	return result.sorted()
+}
+
+func (fp FlatPoints) linearPointsWithin(x, y, dsquared float64) FlatPoints {
+	result := FlatPoints{}
+	for i := 0; i < fp.Len(); i++ {
+		x1, y1 := fp.GetPointAt(i)
+		if math.Pow(x-x1, 2) + math.Pow(y-y1, 2) <= dsquared {
+			result = append(result, x1, y1)
+		}
+	}
+	return result.sorted()
+}
+
+// sorted returns a copy of the points sorted lexicographically, so results in different order can be compared
+func (fp FlatPoints) sorted() FlatPoints {
+	result := append(FlatPoints{}, fp...)
+	sort.Sort(lexicographicSorter(result))
+	return result
+}
+
+// linearKClosestPoints assumes that there are no ties in the distances
+func (fp FlatPoints) linearKClosestPoints(x, y float64, k int) FlatPoints {
+	indexes := make([]int, fp.Len())
+	for i := range indexes {
+		indexes[i] = i
+	}
+	distance := func (i int) float64 {
+		x1, y1 := fp.GetPointAt(indexes[i])
+		return math.Pow(x-x1, 2) + math.Pow(y-y1, 2)
+	}
+	sort.Slice(indexes, func (i, j int) bool {
+		return distance(i) < distance(j)
+	})
+	result := FlatPoints{}
+	for i := 0; i < k && i < len(indexes); i++ {
+		x1, y1 := fp.GetPointAt(indexes[i])
+		result = append(result, x1, y1)
+	}
+	return result
+}
+
 func ExampleSimpleRTree_FindNearestPoint() {
 	points := []float64{0, 0, 1, 1, 0, 1}
 	r := New().Load(FlatPoints(points))
@@ -0,0 +1,147 @@
+//go:build !purego
+
+#include "textflag.h"
+
+// func vectorComputeDistances(bbox rVectorBBox, x, y float64) (mind, maxd float64)
+// +0 Minx
+// +8 MinY
+// +16 MaxX
+// +24 MaxY
+// +32 x
+// +40 y
+// +48 mind
+// +56 maxd
+TEXT ·vectorComputeDistances(SB), $0-64
+MOVSD  bbox_0+0(FP), X0
+MOVSD  bbox_1+8(FP), X5
+MOVSD  bbox_2+16(FP), X1
+MOVSD  bbox_3+24(FP), X6
+MOVSD x+32(FP), X2
+MOVSD y+40(FP), X7
+
+// compute for x
+MOVSD X2, X3
+SUBSD X0, X2 // point - min
+SUBSD X1, X3 // point - max
+MULSD X2, X2 // (point - min) ** 2
+MULSD X3, X3 // (point - max) ** 2
+MOVSD X2, X4 // copy to keep X2
+MINSD X3, X4 // min of (point -min)**2, (point - max)**2
+MAXSD X3, X2 // max of (point -min)**2, (point - max)**2
+SUBSD X0, X1 // max - min
+MULSD X1, X1 // (max - min)**2 (sides)
+CMPSD X2, X1, 2// https://www.felixcloutier.com/x86/CMPPD.html sets 0 bits if false 1 bits if true order seems to be reversed
+PAND X4, X1 // keep minx**2 if X1 is 1 mask (that is point is outside bbox)
+
+// compute for y
+MOVSD X7, X8
+SUBSD X5, X7 // point - min
+SUBSD X6, X8 // point - max
+MULSD X7, X7 // (point - min) ** 2
+MULSD X8, X8 // (point - max) ** 2
+MOVSD X7, X9 // copy to keep X7
+MINSD X8, X9 // min of (point -min)**2, (point - max)**2
+MAXSD X8, X7 // max of (point -min)**2, (point - max)**2
+SUBSD X5, X6 // max - min
+MULSD X6, X6 // (max - min)**2 (sides)
+CMPSD X7, X6, 2// https://www.felixcloutier.com/x86/CMPPD.html sets 0 bits if false 1 bits if true order seems to be reversed
+PAND X9, X6 // keep minx if X1 is 1 mask
+
+// MOVLPS X1, X3 // Move upper float of X1 to lower
+ADDSD X6, X1 // Now X1 contains the sum of both elements
+MOVSD X1, mind+48(FP)
+
+// SHUFPD $0x1, X4, X4 // Invert X4
+ADDSD X9, X2 // Crossed sum
+ADDSD X4, X7
+// MOVLPS X2, X6 // Move one of the mixed sums to X6
+
+MINSD X2, X7 // Min of crossed sums
+MOVSD X7, maxd+56(FP)
+RET
+
+// func vectorComputeDistances4(bbox *rVectorBBox, stride uintptr, x, y float64, mind, maxd *[4]float64)
+// Same computation as vectorComputeDistances for 4 bboxes placed every stride bytes, that is, the children of a node.
+// Bboxes are transposed so that each register holds the same coordinate of the 4 bboxes
+// and every instruction works on the 4 bboxes at the same time. Requires AVX2
+TEXT ·vectorComputeDistances4(SB), NOSPLIT, $0-48
+MOVQ bbox+0(FP), SI
+MOVQ stride+8(FP), DX
+MOVSD x+16(FP), X14
+VBROADCASTSD X14, Y14 // x x x x
+MOVSD y+24(FP), X15
+VBROADCASTSD X15, Y15 // y y y y
+
+VMOVUPD (SI), Y0 // minx0 miny0 maxx0 maxy0
+ADDQ DX, SI
+VMOVUPD (SI), Y1
+ADDQ DX, SI
+VMOVUPD (SI), Y2
+ADDQ DX, SI
+VMOVUPD (SI), Y3
+
+// transpose
+VUNPCKLPD Y1, Y0, Y4 // minx0 minx1 maxx0 maxx1
+VUNPCKHPD Y1, Y0, Y5 // miny0 miny1 maxy0 maxy1
+VUNPCKLPD Y3, Y2, Y6 // minx2 minx3 maxx2 maxx3
+VUNPCKHPD Y3, Y2, Y7 // miny2 miny3 maxy2 maxy3
+VPERM2F128 $0x20, Y6, Y4, Y8 // minx0 minx1 minx2 minx3
+VPERM2F128 $0x31, Y6, Y4, Y9 // maxx
+VPERM2F128 $0x20, Y7, Y5, Y10 // miny
+VPERM2F128 $0x31, Y7, Y5, Y11 // maxy
+
+// compute for x
+VSUBPD Y8, Y14, Y0 // point - min
+VSUBPD Y9, Y14, Y1 // point - max
+VMULPD Y0, Y0, Y0 // (point - min) ** 2
+VMULPD Y1, Y1, Y1 // (point - max) ** 2
+VMINPD Y1, Y0, Y2 // min of (point -min)**2, (point - max)**2
+VMAXPD Y1, Y0, Y3 // max of (point -min)**2, (point - max)**2
+VSUBPD Y8, Y9, Y4 // max - min
+VMULPD Y4, Y4, Y4 // (max - min)**2 (sides)
+VCMPPD $2, Y3, Y4, Y4 // sides <= max, that is point is outside bbox
+VANDPD Y2, Y4, Y4 // keep minx where point is outside
+
+// compute for y
+VSUBPD Y10, Y15, Y0
+VSUBPD Y11, Y15, Y1
+VMULPD Y0, Y0, Y0
+VMULPD Y1, Y1, Y1
+VMINPD Y1, Y0, Y5 // miny
+VMAXPD Y1, Y0, Y6 // maxy
+VSUBPD Y10, Y11, Y7
+VMULPD Y7, Y7, Y7
+VCMPPD $2, Y6, Y7, Y7
+VANDPD Y5, Y7, Y7
+
+MOVQ mind+32(FP), DI
+VADDPD Y7, Y4, Y0
+VMOVUPD Y0, (DI)
+
+// Crossed sums
+MOVQ maxd+40(FP), DI
+VADDPD Y5, Y3, Y1 // maxx + miny
+VADDPD Y6, Y2, Y8 // minx + maxy
+VMINPD Y8, Y1, Y1
+VMOVUPD Y1, (DI)
+VZEROUPPER
+RET
+
+// func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+TEXT ·cpuid(SB), NOSPLIT, $0-24
+MOVL eaxArg+0(FP), AX
+MOVL ecxArg+4(FP), CX
+CPUID
+MOVL AX, eax+8(FP)
+MOVL BX, ebx+12(FP)
+MOVL CX, ecx+16(FP)
+MOVL DX, edx+20(FP)
+RET
+
+// func xgetbv() (eax, edx uint32)
+TEXT ·xgetbv(SB), NOSPLIT, $0-8
+MOVL $0, CX
+XGETBV
+MOVL AX, eax+0(FP)
+MOVL DX, edx+4(FP)
+RET
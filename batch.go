@@ -0,0 +1,126 @@
+package SimpleRTree
+
+import (
+	"math"
+	"sync"
+	"unsafe"
+)
+
+var bbox_offset = unsafe.Offsetof(rNode{}.BBox)
+
+// FindNearestPoints finds the closest point for every point in queries.
+// Coordinates of the closest points are appended to out and distances squared are appended to distances,
+// in the same order as queries. distances can be nil if they are not needed.
+// If there is no point for a query, because the tree is empty or all its points have been deleted,
+// its coordinates are NaN and its distance is +Inf.
+// On amd64 with AVX2 distances to the children of every node are computed four at a time.
+// No allocations are made as long as out and distances have enough capacity
+//  out, distances = r.FindNearestPoints(queries, out[0:0], distances[0:0])
+//  // out[2 * i], out[2 * i + 1] is the closest point to queries[2 * i], queries[2 * i + 1]
+func (r *SimpleRTree) FindNearestPoints(queries FlatPoints, out FlatPoints, distances []float64) (FlatPoints, []float64) {
+	start := len(out) / 2
+	out, distances = growResults(out, distances, queries.Len())
+	if len(r.nodes) == 0 {
+		for i := 0; i < queries.Len(); i++ {
+			setNoPoint(out[2*start:], distancesFrom(distances, start), i)
+		}
+		return out, distances
+	}
+	sq := r.getQueue()
+	sq = r.findNearestPointsRange(sq, queries, 0, queries.Len(), out[2*start:], distancesFrom(distances, start))
+	r.putQueue(sq)
+	return out, distances
+}
+
+// FindNearestPointsParallel works as FindNearestPoints but splits the queries between workers go routines.
+// Every worker takes its own search queue from the pool of the tree. In UnsafeConcurrencyMode there is a single
+// queue, so queries are processed in the calling go routine
+func (r *SimpleRTree) FindNearestPointsParallel(queries FlatPoints, out FlatPoints, distances []float64, workers int) (FlatPoints, []float64) {
+	if workers <= 1 || r.options.UnsafeConcurrencyMode || len(r.nodes) == 0 {
+		return r.FindNearestPoints(queries, out, distances)
+	}
+	start := len(out) / 2
+	out, distances = growResults(out, distances, queries.Len())
+	resultPoints := out[2*start:]
+	resultDistances := distancesFrom(distances, start)
+
+	chunk := (queries.Len() + workers - 1) / workers
+	var wg sync.WaitGroup
+	for from := 0; from < queries.Len(); from += chunk {
+		to := minInt(from+chunk, queries.Len())
+		wg.Add(1)
+		go func(from, to int) {
+			defer wg.Done()
+			sq := r.queuePool.Get().(searchQueue)
+			sq = r.findNearestPointsRange(sq, queries, from, to, resultPoints, resultDistances)
+			r.queuePool.Put(sq)
+		}(from, to)
+	}
+	wg.Wait()
+	return out, distances
+}
+
+// findNearestPointsRange writes the closest point to queries in [from, to) into the same positions of out and distances
+func (r *SimpleRTree) findNearestPointsRange(sq searchQueue, queries FlatPoints, from, to int, out FlatPoints, distances []float64) searchQueue {
+	var index int
+	var d float64
+	var found bool
+	for i := from; i < to; i++ {
+		x, y := queries.GetPointAt(i)
+		index, d, found, sq = r.findNearestPointWithQueue(sq, x, y, math.Inf(1), useBatchedKernel, nil)
+		if !found {
+			setNoPoint(out, distances, i)
+			continue
+		}
+		out[2*i], out[2*i+1] = r.points.GetPointAt(index)
+		if distances != nil {
+			distances[i] = d
+		}
+	}
+	return sq
+}
+
+// setNoPoint writes the result of a query without closest point, NaN coordinates and +Inf distance
+func setNoPoint(out FlatPoints, distances []float64, i int) {
+	out[2*i], out[2*i+1] = math.NaN(), math.NaN()
+	if distances != nil {
+		distances[i] = math.Inf(1)
+	}
+}
+
+// computeChildrenDistances computes mind and maxd for the n nodes starting at first, using vectorComputeDistances4 for groups of 4
+func computeChildrenDistances(first uintptr, n int, x, y float64, minds, maxds *[MAX_POSSIBLE_SIZE]float64) {
+	i := 0
+	f := first
+	for ; i+4 <= n; i += 4 {
+		vectorComputeDistances4(
+			(*rVectorBBox)(unsafe.Pointer(f+bbox_offset)),
+			node_size,
+			x,
+			y,
+			(*[4]float64)(unsafe.Pointer(&minds[i])),
+			(*[4]float64)(unsafe.Pointer(&maxds[i])),
+		)
+		f = f + 4*node_size
+	}
+	for ; i < n; i++ {
+		minds[i], maxds[i] = vectorComputeDistances((*rNode)(unsafe.Pointer(f)).BBox, x, y)
+		f = f + node_size
+	}
+}
+
+// growResults extends out and distances (if not nil) to hold n more results
+func growResults(out FlatPoints, distances []float64, n int) (FlatPoints, []float64) {
+	out = append(out, make(FlatPoints, 2*n)...)
+	if distances != nil {
+		distances = append(distances, make([]float64, n)...)
+	}
+	return out, distances
+}
+
+func distancesFrom(distances []float64, start int) []float64 {
+	if distances == nil {
+		return nil
+	}
+	return distances[start:]
+}
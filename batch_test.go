@@ -0,0 +1,119 @@
+package SimpleRTree
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestVectorComputeDistances4(t *testing.T) {
+	if !useBatchedKernel {
+		t.Skip("no AVX2")
+	}
+	nodes := make([]rNode, 4*100)
+	for i := range nodes {
+		x1, x2 := sortFloats(rand.Float64(), rand.Float64())
+		y1, y2 := sortFloats(rand.Float64(), rand.Float64())
+		nodes[i].BBox = newVectorBBox(x1, y1, x2, y2)
+	}
+	var minds, maxds [4]float64
+	for i := 0; i < len(nodes); i += 4 {
+		x, y := rand.Float64()*2-0.5, rand.Float64()*2-0.5
+		vectorComputeDistances4(&nodes[i].BBox, node_size, x, y, &minds, &maxds)
+		for j := 0; j < 4; j++ {
+			mind, maxd := computeDistances(nodes[i+j].BBox, x, y)
+			assert.InDelta(t, mind, minds[j], 1e-12)
+			assert.InDelta(t, maxd, maxds[j], 1e-12)
+		}
+	}
+}
+
+func TestSimpleRTree_FindNearestPoints(t *testing.T) {
+	const size = 20000
+	const nQueries = 1000
+	points := make([]float64, size*2)
+	for i := 0; i < 2*size; i++ {
+		points[i] = rand.Float64()
+	}
+	queries := make(FlatPoints, 2*nQueries)
+	for i := range queries {
+		queries[i] = rand.Float64()
+	}
+	fp := FlatPoints(points)
+	r := New().Load(fp)
+	rU := NewWithOptions(Options{UnsafeConcurrencyMode: true, MAX_ENTRIES: 6}).Load(append(FlatPoints{}, points...))
+
+	out, distances := r.FindNearestPoints(queries, nil, []float64{})
+	outP, distancesP := r.FindNearestPointsParallel(queries, FlatPoints{-1, -1}, nil, 4)
+	outU, _ := rU.FindNearestPointsParallel(queries, nil, nil, 4)
+	assert.Equal(t, nQueries, len(distances))
+	assert.Nil(t, distancesP)
+	assert.Equal(t, FlatPoints{-1, -1}, outP[0:2], "Results are appended")
+	for i := 0; i < nQueries; i++ {
+		x, y := queries.GetPointAt(i)
+		x1, y1, d := fp.linearClosestPoint(x, y)
+		assert.Equal(t, x1, out[2*i])
+		assert.Equal(t, y1, out[2*i+1])
+		assert.InDelta(t, d, distances[i], 1e-12)
+		assert.Equal(t, x1, outP[2*i+2])
+		assert.Equal(t, y1, outP[2*i+3])
+		assert.Equal(t, x1, outU[2*i])
+		assert.Equal(t, y1, outU[2*i+1])
+	}
+}
+
+func TestSimpleRTree_FindNearestPointsNoPoint(t *testing.T) {
+	queries := FlatPoints{0, 0, 1, 1, 2, 2}
+	r := New().Load(FlatPoints{0, 0, 1, 0, 0, 1})
+	r.Delete(0, 0)
+	r.Delete(1, 0)
+	r.Delete(0, 1)
+	empty := New().Load(FlatPoints{})
+	compacted := New().Load(FlatPoints{0, 0})
+	compacted.Delete(0, 0)
+	compacted.Compact()
+	for _, tree := range []*SimpleRTree{r, empty, compacted} {
+		out, distances := tree.FindNearestPoints(queries, FlatPoints{-1, -1}, []float64{-1})
+		outP, _ := tree.FindNearestPointsParallel(queries, nil, nil, 2)
+		assert.Equal(t, 2*queries.Len()+2, len(out))
+		assert.Equal(t, queries.Len()+1, len(distances))
+		assert.Equal(t, queries.Len(), outP.Len())
+		for i := 0; i < queries.Len(); i++ {
+			assert.True(t, math.IsNaN(out[2*i+2]) && math.IsNaN(out[2*i+3]), "No point is NaN coordinates")
+			assert.True(t, math.IsInf(distances[i+1], 1), "No point is at infinite distance")
+			assert.True(t, math.IsNaN(outP[2*i]) && math.IsNaN(outP[2*i+1]))
+		}
+	}
+}
+
+func BenchmarkSimpleRTree_FindNearestPoints(b *testing.B) {
+	benchmarks := []struct {
+		name    string
+		size    int
+		workers int
+	}{
+		{"1000000", 1000000, 1},
+		{"1000000-parallel", 1000000, 4},
+	}
+	const nQueries = 1000
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			size := bm.size
+			points := make([]float64, size*2)
+			for i := 0; i < 2*size; i++ {
+				points[i] = rand.Float64()
+			}
+			queries := make(FlatPoints, 2*nQueries)
+			for i := range queries {
+				queries[i] = rand.Float64()
+			}
+			out := make(FlatPoints, 0, 2*nQueries)
+			r := New().Load(FlatPoints(points))
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				out, _ = r.FindNearestPointsParallel(queries, out[0:0], nil, bm.workers)
+			}
+		})
+	}
+}
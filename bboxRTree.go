@@ -0,0 +1,256 @@
+package SimpleRTree
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"unsafe"
+)
+
+// FlatBBoxes is a flat array of rectangles, every four coordinates minX, minY, maxX, maxY are a bbox
+type FlatBBoxes []float64
+
+// Len returns the number of bboxes
+func (fb FlatBBoxes) Len() int {
+	return len(fb) / 4
+}
+
+// GetBBoxAt returns the coordinates of the bbox i
+func (fb FlatBBoxes) GetBBoxAt(i int) (minX, minY, maxX, maxY float64) {
+	return fb[4*i], fb[4*i+1], fb[4*i+2], fb[4*i+3]
+}
+
+// BBoxRTree indexes rectangles (for example parcels or building footprints) instead of points.
+// It is built as a SimpleRTree over the centres of the rectangles and the bboxes of the nodes are then extended to
+// contain the rectangles, so the layout and query speed are the same as for points.
+// Queries return the position of the rectangles in the array given to Load
+//
+//	boxes := SimpleRTree.FlatBBoxes{0, 0, 1, 1, 2, 2, 4, 3}
+//	b := SimpleRTree.NewBBoxRTree().Load(boxes)
+//	index, d1 := b.FindNearestBBox(3, 0)
+//	// index == 1, d1 == 4
+type BBoxRTree struct {
+	rtree *SimpleRTree  // built on the centres of the bboxes, the id of each centre is the position of its bbox
+	boxes []rVectorBBox // boxes in the same order as the points of rtree
+}
+
+// NewBBoxRTree returns an instance of a BBoxRTree with default options
+func NewBBoxRTree() *BBoxRTree {
+	return NewBBoxRTreeWithOptions(Options{})
+}
+
+// NewBBoxRTreeWithOptions returns an instance of a BBoxRTree with given options o, it panics if they are not valid.
+// The array of bboxes is never modified, so CopyPoints has no effect. Only euclidean distance is supported, so Geodesic and Metric cannot be set
+func NewBBoxRTreeWithOptions(o Options) *BBoxRTree {
+	if o.metric() != nil {
+		panic(fmt.Errorf("%w: only euclidean distance is supported for bboxes", ErrInvalidOptions).Error())
+	}
+	r := NewWithOptions(o)
+	r.options.CopyPoints = false
+	return &BBoxRTree{rtree: r}
+}
+
+// Load builds the tree with the given bboxes. The bboxes are copied, so the array can be reused afterwards
+func (b *BBoxRTree) Load(boxes FlatBBoxes) *BBoxRTree {
+	if err := b.loadErr(boxes); err != nil {
+		log.Fatal(err)
+	}
+	return b
+}
+
+// LoadErr works as Load but returns an error instead of exiting the process.
+// Bboxes are checked with FlatBBoxes.Validate before building the tree
+func (b *BBoxRTree) LoadErr(boxes FlatBBoxes) (*BBoxRTree, error) {
+	if err := boxes.Validate(); err != nil {
+		return b, err
+	}
+	return b, b.loadErr(boxes)
+}
+
+func (b *BBoxRTree) loadErr(boxes FlatBBoxes) error {
+	centres := make(FlatPoints, 2*boxes.Len())
+	for i := 0; i < boxes.Len(); i++ {
+		minX, minY, maxX, maxY := boxes.GetBBoxAt(i)
+		// halves are added so that the sum cannot overflow
+		centres[2*i] = minX/2 + maxX/2
+		centres[2*i+1] = minY/2 + maxY/2
+	}
+	if err := b.rtree.loadErr(centres, identityIDs(boxes.Len()), false); err != nil {
+		return err
+	}
+	if boxes.Len() == 0 {
+		return nil
+	}
+	b.boxes = make([]rVectorBBox, boxes.Len())
+	for i, id := range b.rtree.ids {
+		b.boxes[i] = newVectorBBox(boxes.GetBBoxAt(id))
+	}
+	b.refit(0)
+	return nil
+}
+
+// refit sets the bbox of the node and its descendants to the union of the bboxes below it
+func (b *BBoxRTree) refit(nodeIndex int) rVectorBBox {
+	n := &b.rtree.nodes[nodeIndex]
+	first := int(n.firstChild)
+	var bbox rVectorBBox
+	for i := first; i < first+int(n.nChildren); i++ {
+		var child rVectorBBox
+		if n.nodeType == preleaf_node {
+			child = b.boxes[i]
+		} else {
+			child = b.refit(i)
+		}
+		if i == first {
+			bbox = child
+		} else {
+			bbox = vectorBBoxExtend(bbox, child)
+		}
+	}
+	// n is still valid, nodes are not reallocated
+	n.BBox = bbox
+	return bbox
+}
+
+// Destroy frees up resources that are held within the tree, see SimpleRTree.Destroy
+func (b *BBoxRTree) Destroy() {
+	b.rtree.Destroy()
+}
+
+// Len returns the number of bboxes in the tree
+func (b *BBoxRTree) Len() int {
+	return len(b.boxes)
+}
+
+// FindNearestBBox returns the position of the closest bbox to x, y and the distance squared to it.
+// The distance is 0 if the bbox contains x, y
+//
+//	index, d1 := b.FindNearestBBox(x, y)
+//	minX, minY, maxX, maxY := boxes.GetBBoxAt(index)
+func (b *BBoxRTree) FindNearestBBox(x, y float64) (index int, d1 float64) {
+	index, d1, _ = b.FindNearestBBoxWithin(x, y, math.Inf(1))
+	return
+}
+
+// FindNearestBBoxWithin works as FindNearestBBox for bboxes within the distance squared dsquared.
+// In case there is no bbox within dsquared found will return false
+func (b *BBoxRTree) FindNearestBBoxWithin(x, y, dsquared float64) (index int, d1 float64, found bool) {
+	position, d1, found := b.findNearestWithin(x, y, dsquared, nil)
+	if !found {
+		return
+	}
+	return b.rtree.ids[position], d1, true
+}
+
+// findNearestWithin returns the position of the closest item. The distance to the item in a given position is
+// computed by distance, it must not be smaller than the distance to its bbox. If distance is nil items are the bboxes
+func (b *BBoxRTree) findNearestWithin(x, y, dsquared float64, distance func(position int) float64) (position int, d1 float64, found bool) {
+	r := b.rtree
+	if len(r.nodes) == 0 {
+		return
+	}
+	sq := r.getQueue()
+	var minItem searchQueueItem
+	distanceLowerBound := math.Inf(1)
+	distanceUpperBound := dsquared
+
+	unsafeRootNode := uintptr(unsafe.Pointer(&r.nodes[0]))
+	sq = append(sq, searchQueueItem{node: unsafeRootNode, distance: 0})
+
+	for sq.Len() > 0 {
+		sq.PreparePop()
+		item := sq[sq.Len()-1]
+		sq = sq[0 : sq.Len()-1]
+		if found && item.distance > distanceLowerBound {
+			break
+		}
+
+		node := (*rNode)(unsafe.Pointer(item.node))
+		if node == nil { // bbox or segment
+			distanceLowerBound = item.distance
+			minItem = item
+			found = true
+			continue
+		}
+		switch node.nodeType {
+		case preleaf_node:
+			first := int(node.firstChild)
+			for i := first; i < first+int(node.nChildren); i++ {
+				d, _ := vectorComputeDistances(b.boxes[i], x, y)
+				if distance != nil && d <= distanceUpperBound {
+					d = distance(i)
+				}
+				if d <= distanceUpperBound {
+					sq = append(sq, searchQueueItem{index: i, distance: d})
+					distanceUpperBound = d
+				}
+			}
+		default:
+			f := nodeAddress(unsafeRootNode, node.firstChild)
+			var i uint8
+			for i = node.nChildren; i > 0; i-- {
+				n := (*rNode)(unsafe.Pointer(f))
+				mind, maxd := vectorComputeDistances(n.BBox, x, y)
+				if mind <= distanceUpperBound {
+					sq = append(sq, searchQueueItem{node: f, distance: mind})
+					// Every side of the node touches one of the bboxes below it, so as for points
+					// there is a bbox within maxd. Segments touch every side of their bbox, so it holds for them too
+					if maxd < distanceUpperBound {
+						distanceUpperBound = maxd
+					}
+				}
+				f = f + node_size
+			}
+		}
+	}
+	r.putQueue(sq)
+
+	if !found {
+		return
+	}
+	return minItem.index, minItem.distance, true
+}
+
+// Search appends to dst the positions of the bboxes that intersect the bbox given by minX, minY, maxX, maxY.
+// Bboxes that only touch it are included. The order of the result is not guaranteed
+//
+//	indexes := b.Search(minX, minY, maxX, maxY, nil)
+func (b *BBoxRTree) Search(minX, minY, maxX, maxY float64, dst []int) []int {
+	r := b.rtree
+	if len(r.nodes) == 0 {
+		return dst
+	}
+	bbox := rBBox{MinX: minX, MinY: minY, MaxX: maxX, MaxY: maxY}
+	// nodes are traversed as in SimpleRTree.search
+	sq := r.getQueue()
+	unsafeRootNode := uintptr(unsafe.Pointer(&r.nodes[0]))
+	sq = append(sq, searchQueueItem{node: unsafeRootNode})
+
+	for sq.Len() > 0 {
+		item := sq[sq.Len()-1]
+		sq = sq[0 : sq.Len()-1]
+
+		node := (*rNode)(unsafe.Pointer(item.node))
+		switch node.nodeType {
+		case preleaf_node:
+			first := int(node.firstChild)
+			for i := first; i < first+int(node.nChildren); i++ {
+				if bbox.intersects(b.boxes[i].toBBox()) {
+					dst = append(dst, r.ids[i])
+				}
+			}
+		default:
+			f := nodeAddress(unsafeRootNode, node.firstChild)
+			var i uint8
+			for i = node.nChildren; i > 0; i-- {
+				n := (*rNode)(unsafe.Pointer(f))
+				if bbox.intersects(n.BBox.toBBox()) {
+					sq = append(sq, searchQueueItem{node: f})
+				}
+				f = f + node_size
+			}
+		}
+	}
+	r.putQueue(sq)
+	return dst
+}
@@ -0,0 +1,127 @@
+package SimpleRTree
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestBBoxRTree(t *testing.T) {
+	const size = 2000
+	boxes := make(FlatBBoxes, 0, 4*size)
+	for i := 0; i < size; i++ {
+		x, y := rand.Float64(), rand.Float64()
+		boxes = append(boxes, x, y, x+0.05*rand.Float64(), y+0.05*rand.Float64())
+	}
+	original := append(FlatBBoxes{}, boxes...)
+	for _, options := range []Options{{}, {MAX_ENTRIES: 4}, {TreeType: HILBERT}, {UnsafeConcurrencyMode: true}} {
+		b := NewBBoxRTreeWithOptions(options).Load(boxes)
+		assert.Equal(t, original, boxes, "Bboxes are not modified")
+		assert.Equal(t, size, b.Len())
+		checkBBoxNodes(t, b, 0)
+
+		for i := 0; i < 100; i++ {
+			x, y := 1.2*rand.Float64()-0.1, 1.2*rand.Float64()-0.1
+			index, d := b.FindNearestBBox(x, y)
+			expected := boxes.linearBBoxDistance(x, y)
+			assert.Equal(t, expected, d)
+			assert.Equal(t, expected, bboxDistance(boxes, index, x, y))
+
+			_, _, found := b.FindNearestBBoxWithin(x, y, expected)
+			assert.True(t, found)
+			if expected > 0 {
+				_, _, found = b.FindNearestBBoxWithin(x, y, expected/2)
+				assert.False(t, found)
+			}
+
+			minX, minY := rand.Float64(), rand.Float64()
+			maxX, maxY := minX+0.1*rand.Float64(), minY+0.1*rand.Float64()
+			result := b.Search(minX, minY, maxX, maxY, []int{})
+			sort.Ints(result)
+			assert.Equal(t, boxes.linearSearch(minX, minY, maxX, maxY), result)
+		}
+	}
+
+	// Nearest bbox is the one containing the point, even if other centres are closer
+	b := NewBBoxRTree().Load(FlatBBoxes{0, 0, 10, 10, 5.5, 5.5, 5.6, 5.6, 20, 20, 21, 21})
+	index, d := b.FindNearestBBox(1, 1)
+	assert.Equal(t, 0, index)
+	assert.Equal(t, 0., d)
+	index, d = b.FindNearestBBox(12, 10)
+	assert.Equal(t, 0, index)
+	assert.Equal(t, 4., d)
+	assert.Equal(t, []int{0, 1}, b.Search(5.6, 5.6, 6, 6, []int{}), "Touching bboxes are found")
+
+	empty := NewBBoxRTree().Load(FlatBBoxes{})
+	_, _, found := empty.FindNearestBBoxWithin(0, 0, math.Inf(1))
+	assert.False(t, found)
+	assert.Equal(t, 0, len(empty.Search(0, 0, 1, 1, nil)))
+}
+
+func TestBBoxRTree_LoadErr(t *testing.T) {
+	_, err := NewBBoxRTree().LoadErr(FlatBBoxes{0, 0, 1, 1, 2, 2, 1, 3})
+	var ve *ValidationError
+	assert.ErrorAs(t, err, &ve)
+	assert.Equal(t, 1, ve.Index)
+	assert.Equal(t, ErrInvertedBBox, ve.Kind)
+	assert.Equal(t, "SimpleRTree: bbox with min coordinate greater than max: bbox 1", err.Error())
+
+	b, err := NewBBoxRTree().LoadErr(FlatBBoxes{0, 0, 1, 1})
+	assert.NoError(t, err)
+	_, err = b.LoadErr(FlatBBoxes{0, 0, 1, 1})
+	assert.ErrorIs(t, err, ErrAlreadyBuilt)
+}
+
+// checkBBoxNodes checks that every node contains the bboxes below it
+func checkBBoxNodes(t *testing.T, b *BBoxRTree, nodeIndex int) {
+	n := b.rtree.nodes[nodeIndex]
+	first := int(n.firstChild)
+	for i := first; i < first+int(n.nChildren); i++ {
+		child := b.boxes[i]
+		if n.nodeType == default_node {
+			child = b.rtree.nodes[i].BBox
+			checkBBoxNodes(t, b, i)
+		}
+		assert.True(t, n.BBox.toBBox().contains(child.toBBox()))
+	}
+}
+
+func bboxDistance(boxes FlatBBoxes, i int, x, y float64) float64 {
+	d, _ := computeDistances(newVectorBBox(boxes.GetBBoxAt(i)), x, y)
+	return d
+}
+
+func (fb FlatBBoxes) linearBBoxDistance(x, y float64) float64 {
+	d := math.Inf(1)
+	for i := 0; i < fb.Len(); i++ {
+		d = math.Min(d, bboxDistance(fb, i, x, y))
+	}
+	return d
+}
+
+func (fb FlatBBoxes) linearSearch(minX, minY, maxX, maxY float64) []int {
+	result := []int{}
+	bbox := rBBox{MinX: minX, MinY: minY, MaxX: maxX, MaxY: maxY}
+	for i := 0; i < fb.Len(); i++ {
+		if bbox.intersects(newVectorBBox(fb.GetBBoxAt(i)).toBBox()) {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+func BenchmarkBBoxRTree_FindNearestBBox(b *testing.B) {
+	const size = 1000000
+	boxes := make(FlatBBoxes, 0, 4*size)
+	for i := 0; i < size; i++ {
+		x, y := rand.Float64(), rand.Float64()
+		boxes = append(boxes, x, y, x+0.001*rand.Float64(), y+0.001*rand.Float64())
+	}
+	r := NewBBoxRTreeWithOptions(Options{UnsafeConcurrencyMode: true}).Load(boxes)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.FindNearestBBox(rand.Float64(), rand.Float64())
+	}
+}
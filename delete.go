@@ -0,0 +1,140 @@
+package SimpleRTree
+
+// Deleted points are only marked (tombstoned), queries skip them but the nodes keep their bboxes.
+// Once many points are deleted queries get slower, Compact rebuilds the tree with the remaining points.
+//
+// Note: Delete, DeleteByID and Compact must not be called concurrently with queries
+
+// Delete removes one point with coordinates x, y from the tree. It returns false if there was no such point
+//  r.Delete(x, y)
+//  if r.TombstoneRatio() > 0.3 {
+//    r.Compact()
+//  }
+func (r *SimpleRTree) Delete(x, y float64) bool {
+	position := -1
+	r.search(rBBox{MinX: x, MinY: y, MaxX: x, MaxY: y}, func(i int) {
+		if position == -1 {
+			position = i
+		}
+	})
+	if position == -1 {
+		return false
+	}
+	r.markDeleted(position)
+	return true
+}
+
+// DeleteByID removes the point with the given id, ids are assumed to be unique.
+// It returns false if there is no point with that id or it was already deleted.
+// If the tree was loaded without ids, id is the position of the point, see FindNearestPointID
+func (r *SimpleRTree) DeleteByID(id int) bool {
+	position := id
+	if r.ids != nil {
+		if r.idPositions == nil {
+			r.idPositions = make(map[int]int, len(r.ids))
+			for i, id := range r.ids {
+				r.idPositions[id] = i
+			}
+		}
+		p, ok := r.idPositions[id]
+		if !ok {
+			return false
+		}
+		position = p
+	}
+	if position < 0 || position >= r.points.Len() || (r.deleted != nil && r.deleted[position]) {
+		return false
+	}
+	r.markDeleted(position)
+	return true
+}
+
+// TombstoneRatio returns the fraction of points of the tree that have been deleted
+func (r *SimpleRTree) TombstoneRatio() float64 {
+	if r.points.Len() == 0 {
+		return 0
+	}
+	return float64(r.nDeleted) / float64(r.points.Len())
+}
+
+// Compact rebuilds the tree with the points that have not been deleted, ids are kept.
+// It is called by Delete when Options.CompactThreshold is set.
+// If the tree was loaded without ids, positions of the points change.
+// A tree opened with OpenMapped is copied to the heap and its file unmapped, Close is then a no-op
+func (r *SimpleRTree) Compact() {
+	if r.nDeleted == 0 {
+		return
+	}
+	points := r.appendLivePoints(make(FlatPoints, 0, 2*(r.points.Len()-r.nDeleted)))
+	var ids []int
+	if r.ids != nil {
+		ids = make([]int, 0, len(points)/2)
+		for i, id := range r.ids {
+			if !r.deleted[i] {
+				ids = append(ids, id)
+			}
+		}
+	}
+	options, mapped := r.options, r.mapped
+	r.Destroy()
+	*r = SimpleRTree{options: options}
+	if mapped != nil {
+		// points and ids were copied above, nothing of the new tree points to the file
+		munmapFile(mapped)
+	}
+	if len(points) == 0 {
+		// loadErr leaves empty trees unbuilt, but this one was built already
+		r.built = true
+		return
+	}
+	if err := r.loadErr(points, ids, false); err != nil {
+		// points come from a valid tree
+		panic(err.Error())
+	}
+}
+
+// appendLivePoints appends to dst the points that have not been deleted
+func (r *SimpleRTree) appendLivePoints(dst FlatPoints) FlatPoints {
+	if r.deleted == nil {
+		return append(dst, r.points...)
+	}
+	for i := 0; i < r.points.Len(); i++ {
+		if !r.deleted[i] {
+			dst = append(dst, r.points[2*i], r.points[2*i+1])
+		}
+	}
+	return dst
+}
+
+func (r *SimpleRTree) markDeleted(position int) {
+	if r.deleted == nil {
+		r.deleted = make([]bool, r.points.Len())
+	}
+	r.deleted[position] = true
+	r.nDeleted++
+	if r.options.CompactThreshold > 0 && r.TombstoneRatio() > r.options.CompactThreshold {
+		r.Compact()
+	}
+}
+
+// Delete removes one point with coordinates x, y from the tree. It returns false if there was no such point.
+// Static trees compact themselves if Options.CompactThreshold is set
+func (d *DynamicRTree) Delete(x, y float64) bool {
+	for i := 0; i < d.buffer.Len(); i++ {
+		px, py := d.buffer.GetPointAt(i)
+		if px == x && py == y {
+			last := d.buffer.Len() - 1
+			d.buffer.Swap(i, last)
+			d.buffer = d.buffer[0 : 2*last]
+			d.size--
+			return true
+		}
+	}
+	for _, t := range d.trees {
+		if t != nil && t.Delete(x, y) {
+			d.size--
+			return true
+		}
+	}
+	return false
+}
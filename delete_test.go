@@ -0,0 +1,133 @@
+package SimpleRTree
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func TestSimpleRTree_Delete(t *testing.T) {
+	const size = 2000
+	points := make([]float64, size*2)
+	for i := 0; i < 2*size; i++ {
+		points[i] = rand.Float64()
+	}
+	original := FlatPoints(points)
+	for _, treeType := range []TreeType{STR, HILBERT} {
+		r := NewWithOptions(Options{TreeType: treeType, CopyPoints: true}).LoadWithIDs(original, nil)
+		live := FlatPoints{}
+		liveIDs := map[int]bool{}
+		for i := 0; i < size; i++ {
+			x, y := original.GetPointAt(i)
+			switch i % 3 {
+			case 0:
+				assert.True(t, r.Delete(x, y))
+				assert.False(t, r.Delete(x, y), "Already deleted")
+			case 1:
+				assert.True(t, r.DeleteByID(i))
+				assert.False(t, r.DeleteByID(i), "Already deleted")
+			default:
+				live = append(live, x, y)
+				liveIDs[i] = true
+			}
+		}
+		assert.False(t, r.Delete(2, 2))
+		assert.False(t, r.DeleteByID(size))
+		assert.InDelta(t, 2./3, r.TombstoneRatio(), 0.01)
+
+		checkQueries := func() {
+			for i := 0; i < 100; i++ {
+				x, y := rand.Float64(), rand.Float64()
+				id, x1, y1, _ := r.FindNearestPointID(x, y)
+				x2, y2, _ := live.linearClosestPoint(x, y)
+				assert.Equal(t, x2, x1)
+				assert.Equal(t, y2, y1)
+				assert.True(t, liveIDs[id])
+				assert.Equal(t, FlatPoints{x1, y1}, original.pointsOf([]int{id}))
+
+				_, _, _, found := r.FindNearestPointWithin(x, y, 1e-12)
+				_, _, d := live.linearClosestPoint(x, y)
+				assert.Equal(t, d <= 1e-12, found)
+				assert.Equal(t, live.linearKClosestPoints(x, y, 5), r.FindKNearestPoints(x, y, 5, nil))
+				assert.Equal(t, live.linearSearch(x, y, x+0.1, y+0.1), r.Search(x, y, x+0.1, y+0.1, FlatPoints{}).sorted())
+				assert.Equal(t, live.linearPointsWithin(x, y, 0.005), r.FindPointsWithin(x, y, 0.005, FlatPoints{}).sorted())
+			}
+			queries := FlatPoints{0.1, 0.2, 0.7, 0.3, 0.5, 0.5}
+			out, _ := r.FindNearestPoints(queries, nil, nil)
+			for i := 0; i < queries.Len(); i++ {
+				x2, y2, _ := live.linearClosestPoint(queries.GetPointAt(i))
+				assert.Equal(t, FlatPoints{x2, y2}, out[2*i:2*i+2])
+			}
+		}
+		checkQueries()
+		r.Compact()
+		assert.Equal(t, 0., r.TombstoneRatio())
+		assert.Equal(t, live.Len(), r.points.Len())
+		checkQueries()
+
+		// Every point deleted
+		for i := 0; i < live.Len(); i++ {
+			assert.True(t, r.Delete(live.GetPointAt(i)))
+		}
+		r.Compact()
+		assert.True(t, r.built)
+		assert.Equal(t, 0, r.points.Len())
+		assert.Equal(t, 0., r.TombstoneRatio())
+		_, _, _, found := r.FindNearestPointWithin(0.5, 0.5, 1)
+		assert.False(t, found)
+		assert.Equal(t, FlatPoints{}, r.Search(0, 0, 1, 1, FlatPoints{}))
+		assert.Nil(t, r.FindKNearestPoints(0.5, 0.5, 5, nil))
+		_, err := r.LoadErr(original)
+		assert.True(t, errors.Is(err, ErrAlreadyBuilt), "Compacted tree is built")
+	}
+}
+
+func TestSimpleRTree_CompactThreshold(t *testing.T) {
+	points := FlatPoints{0, 0, 1, 1, 2, 2, 3, 3}
+	r := NewWithOptions(Options{CompactThreshold: 0.4}).Load(points)
+	assert.True(t, r.Delete(0, 0))
+	assert.Equal(t, 0.25, r.TombstoneRatio())
+	assert.True(t, r.Delete(1, 1))
+	assert.Equal(t, 0., r.TombstoneRatio(), "Tree was compacted")
+	assert.Equal(t, FlatPoints{2, 2, 3, 3}, r.Search(-1, -1, 5, 5, FlatPoints{}).sorted())
+
+	_, err := NewWithOptionsErr(Options{CompactThreshold: 2})
+	assert.True(t, errors.Is(err, ErrInvalidOptions))
+}
+
+func TestDynamicRTree_Delete(t *testing.T) {
+	const size = 1000
+	d := NewDynamicWithOptions(Options{CompactThreshold: 0.5})
+	inserted := make(FlatPoints, 0, 2*size)
+	for i := 0; i < size; i++ {
+		x, y := rand.Float64(), rand.Float64()
+		d.Insert(x, y)
+		inserted = append(inserted, x, y)
+	}
+	live := FlatPoints{}
+	for i := 0; i < size; i++ {
+		x, y := inserted.GetPointAt(i)
+		if i%2 == 0 {
+			assert.True(t, d.Delete(x, y))
+		} else {
+			live = append(live, x, y)
+		}
+	}
+	assert.False(t, d.Delete(2, 2))
+	assert.Equal(t, live.Len(), d.Len())
+	// Deleted points are not merged into the new trees
+	for i := 0; i < 300; i++ {
+		x, y := rand.Float64(), rand.Float64()
+		d.Insert(x, y)
+		live = append(live, x, y)
+	}
+	for i := 0; i < 100; i++ {
+		x, y := rand.Float64(), rand.Float64()
+		x1, y1, _ := d.FindNearestPoint(x, y)
+		x2, y2, _ := live.linearClosestPoint(x, y)
+		assert.Equal(t, x2, x1)
+		assert.Equal(t, y2, y1)
+		assert.Equal(t, live.linearKClosestPoints(x, y, 5), d.FindKNearestPoints(x, y, 5, nil))
+	}
+}
@@ -0,0 +1,199 @@
+package SimpleRTree
+
+import (
+	"math"
+	"sort"
+)
+
+// Size of the buffer of a DynamicRTree. Points in the buffer are scanned linearly
+const dynamic_buffer_size = 256
+
+// DynamicRTree accepts points after it has been built. New points go to a small buffer that is searched linearly,
+// when the buffer is full it is merged with the static trees of smaller size into a new SimpleRTree (logarithmic method).
+// Tree i holds at most dynamic_buffer_size * 2**i points, so every point is rebuilt O(log n) times and queries visit O(log n) trees.
+// Inserted points are visible to queries immediately.
+//  d := SimpleRTree.NewDynamic()
+//  d.Insert(0, 0)
+//  d.Insert(1, 1)
+//  x1, y1, d1 := d.FindNearestPoint(1, 3)
+//  // 1, 1, 4
+//
+// Note: Insert must not be called concurrently with other methods. Queries can be run concurrently
+// in the same cases as for SimpleRTree
+type DynamicRTree struct {
+	options Options
+	buffer  FlatPoints
+	trees   []*SimpleRTree // trees[i] is nil or holds at most dynamic_buffer_size * 2**i points
+	size    int
+}
+
+// NewDynamic returns an empty DynamicRTree with default options
+func NewDynamic() *DynamicRTree {
+	return NewDynamicWithOptions(Options{})
+}
+
+// NewDynamicWithOptions returns an empty DynamicRTree, options o are used for every static tree.
+// It panics if the options are not valid, see NewWithOptionsErr.
+// Points are always copied to memory owned by the tree, so Options.CopyPoints has no effect
+func NewDynamicWithOptions(o Options) *DynamicRTree {
+	r := NewWithOptions(o)
+	o = r.options
+	o.CopyPoints = false
+	return &DynamicRTree{
+		options: o,
+		buffer:  make(FlatPoints, 0, 2*dynamic_buffer_size),
+	}
+}
+
+// Insert adds the point x, y to the tree
+func (d *DynamicRTree) Insert(x, y float64) {
+	d.buffer = append(d.buffer, x, y)
+	d.size++
+	if d.buffer.Len() >= dynamic_buffer_size {
+		d.flush()
+	}
+}
+
+// Len returns the number of points in the tree
+func (d *DynamicRTree) Len() int {
+	return d.size
+}
+
+// Destroy calls Destroy on every static tree, so memory is returned to Options.RTreePool if given
+func (d *DynamicRTree) Destroy() {
+	for i, t := range d.trees {
+		if t != nil {
+			t.Destroy()
+			d.trees[i] = nil
+		}
+	}
+}
+
+// flush merges the buffer and the trees below the first empty slot into a new tree in that slot
+func (d *DynamicRTree) flush() {
+	slot := 0
+	n := d.buffer.Len()
+	for slot < len(d.trees) && d.trees[slot] != nil {
+		n += d.trees[slot].points.Len()
+		slot++
+	}
+	points := make(FlatPoints, 0, 2*n)
+	points = append(points, d.buffer...)
+	for i := 0; i < slot; i++ {
+		points = d.trees[i].appendLivePoints(points)
+		d.trees[i].Destroy()
+		d.trees[i] = nil
+	}
+	if slot == len(d.trees) {
+		d.trees = append(d.trees, nil)
+	}
+	d.trees[slot] = NewWithOptions(d.options).Load(points)
+	d.buffer = d.buffer[0:0]
+}
+
+// FindNearestPoint works as SimpleRTree.FindNearestPoint
+func (d *DynamicRTree) FindNearestPoint(x, y float64) (x1, y1, d1 float64) {
+	x1, y1, d1, _ = d.FindNearestPointWithin(x, y, math.Inf(1))
+	return
+}
+
+// FindNearestPointWithin works as SimpleRTree.FindNearestPointWithin
+func (d *DynamicRTree) FindNearestPointWithin(x, y, dsquared float64) (x1, y1, d1 float64, found bool) {
+	m := d.options.metric()
+	for i := 0; i < d.buffer.Len(); i++ {
+		px, py := d.buffer.GetPointAt(i)
+		dp := pointDistance(m, px, py, x, y)
+		if dp <= dsquared {
+			x1, y1, d1, found = px, py, dp, true
+			dsquared = dp
+		}
+	}
+	for _, t := range d.trees {
+		if t == nil {
+			continue
+		}
+		// trees are searched only within the best distance so far
+		if tx, ty, td, tFound := t.FindNearestPointWithin(x, y, dsquared); tFound {
+			x1, y1, d1, found = tx, ty, td, true
+			dsquared = td
+		}
+	}
+	return
+}
+
+// FindKNearestPoints works as SimpleRTree.FindKNearestPoints
+func (d *DynamicRTree) FindKNearestPoints(x, y float64, k int, dst FlatPoints) FlatPoints {
+	return d.FindKNearestPointsWithin(x, y, math.Inf(1), k, dst)
+}
+
+// FindKNearestPointsWithin works as SimpleRTree.FindKNearestPointsWithin.
+// Candidates of every tree are appended to dst and only the k closest are kept
+func (d *DynamicRTree) FindKNearestPointsWithin(x, y, dsquared float64, k int, dst FlatPoints) FlatPoints {
+	if k <= 0 {
+		return dst
+	}
+	m := d.options.metric()
+	start := len(dst)
+	dst = d.buffer.appendWithin(m, x, y, dsquared, dst)
+	dst, dsquared = keepKNearest(m, x, y, k, dsquared, dst, start)
+	for _, t := range d.trees {
+		if t == nil {
+			continue
+		}
+		dst = t.FindKNearestPointsWithin(x, y, dsquared, k, dst)
+		dst, dsquared = keepKNearest(m, x, y, k, dsquared, dst, start)
+	}
+	return dst
+}
+
+// keepKNearest sorts the candidates in dst[start:], truncates them to k and returns
+// the new bound for the distance, which is the distance to the k-th point once there are k candidates.
+// Distances are measured with m, see Options.Geodesic
+func keepKNearest(m Metric, x, y float64, k int, dsquared float64, dst FlatPoints, start int) (FlatPoints, float64) {
+	candidates := dst[start:]
+	sort.Sort(distanceSorter{points: candidates, x: x, y: y, metric: m})
+	if candidates.Len() < k {
+		return dst, dsquared
+	}
+	dst = dst[0 : start+2*k]
+	x1, y1 := dst.GetPointAt(dst.Len() - 1)
+	return dst, pointDistance(m, x1, y1, x, y)
+}
+
+// Search works as SimpleRTree.Search
+func (d *DynamicRTree) Search(minX, minY, maxX, maxY float64, dst FlatPoints) FlatPoints {
+	for i := 0; i < d.buffer.Len(); i++ {
+		px, py := d.buffer.GetPointAt(i)
+		if px >= minX && px <= maxX && py >= minY && py <= maxY {
+			dst = append(dst, px, py)
+		}
+	}
+	for _, t := range d.trees {
+		if t != nil {
+			dst = t.Search(minX, minY, maxX, maxY, dst)
+		}
+	}
+	return dst
+}
+
+// FindPointsWithin works as SimpleRTree.FindPointsWithin
+func (d *DynamicRTree) FindPointsWithin(x, y, dsquared float64, dst FlatPoints) FlatPoints {
+	dst = d.buffer.appendWithin(d.options.metric(), x, y, dsquared, dst)
+	for _, t := range d.trees {
+		if t != nil {
+			dst = t.FindPointsWithin(x, y, dsquared, dst)
+		}
+	}
+	return dst
+}
+
+// appendWithin appends to dst the points of fp within the distance squared dsquared measured with m, scanning them linearly
+func (fp FlatPoints) appendWithin(m Metric, x, y, dsquared float64, dst FlatPoints) FlatPoints {
+	for i := 0; i < fp.Len(); i++ {
+		px, py := fp.GetPointAt(i)
+		if pointDistance(m, px, py, x, y) <= dsquared {
+			dst = append(dst, px, py)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,74 @@
+package SimpleRTree
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func TestDynamicRTree_Insert(t *testing.T) {
+	const size = 3000
+	d := NewDynamic()
+	dH := NewDynamicWithOptions(Options{TreeType: HILBERT, MAX_ENTRIES: 4})
+	x1, y1, d1, found := d.FindNearestPointWithin(0.5, 0.5, 1)
+	assert.False(t, found)
+	assert.Equal(t, 0., x1+y1+d1)
+	assert.Equal(t, FlatPoints{}, d.FindKNearestPoints(0.5, 0.5, 3, FlatPoints{}))
+
+	inserted := FlatPoints{}
+	for i := 0; i < size; i++ {
+		x, y := rand.Float64(), rand.Float64()
+		d.Insert(x, y)
+		dH.Insert(x, y)
+		inserted = append(inserted, x, y)
+		if i%97 != 0 {
+			continue
+		}
+		assert.Equal(t, i+1, d.Len())
+		for _, tree := range []*DynamicRTree{d, dH} {
+			for j := 0; j < 5; j++ {
+				x, y := rand.Float64(), rand.Float64()
+				x1, y1, d1 := tree.FindNearestPoint(x, y)
+				x2, y2, d2 := inserted.linearClosestPoint(x, y)
+				assert.Equal(t, x2, x1)
+				assert.Equal(t, y2, y1)
+				assert.Equal(t, d2, d1)
+
+				assert.Equal(t, inserted.linearKClosestPoints(x, y, 7), tree.FindKNearestPoints(x, y, 7, nil))
+				assert.Equal(t, inserted.linearSearch(x, y, x+0.1, y+0.1), tree.Search(x, y, x+0.1, y+0.1, FlatPoints{}).sorted())
+				assert.Equal(t, inserted.linearPointsWithin(x, y, 0.005), tree.FindPointsWithin(x, y, 0.005, FlatPoints{}).sorted())
+			}
+		}
+	}
+	// Inserted point is seen straight away
+	d.Insert(10, 10)
+	x1, y1, _ = d.FindNearestPoint(11, 11)
+	assert.Equal(t, 10., x1)
+	assert.Equal(t, 10., y1)
+
+	for i, tr := range d.trees {
+		if tr != nil {
+			assert.True(t, tr.points.Len() <= dynamic_buffer_size<<uint(i))
+		}
+	}
+}
+
+func BenchmarkDynamicRTree_Insert(b *testing.B) {
+	d := NewDynamic()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		d.Insert(rand.Float64(), rand.Float64())
+	}
+}
+
+func BenchmarkDynamicRTree_FindNearestPoint(b *testing.B) {
+	const size = 1000000
+	d := NewDynamicWithOptions(Options{UnsafeConcurrencyMode: true})
+	for i := 0; i < size; i++ {
+		d.Insert(rand.Float64(), rand.Float64())
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		_, _, _ = d.FindNearestPoint(rand.Float64(), rand.Float64())
+	}
+}
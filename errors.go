@@ -0,0 +1,191 @@
+package SimpleRTree
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Errors returned by the error returning constructor, loaders and ReadFrom. They are wrapped with some context,
+// so they should be compared with errors.Is
+//  r, err := SimpleRTree.New().LoadErr(fp)
+//  if errors.Is(err, SimpleRTree.ErrNaNCoordinate) {
+//    ...
+//  }
+var (
+	ErrAlreadyBuilt       = errors.New("SimpleRTree: tree is static, cannot load twice")
+	ErrTooManyPoints      = errors.New("SimpleRTree: exceeded maximum possible size")
+	ErrInvalidOptions     = errors.New("SimpleRTree: invalid options")
+	ErrOddLength          = errors.New("SimpleRTree: odd number of coordinates in FlatPoints")
+	ErrBBoxLength         = errors.New("SimpleRTree: number of coordinates in FlatBBoxes is not a multiple of 4")
+	ErrInvertedBBox       = errors.New("SimpleRTree: bbox with min coordinate greater than max")
+	ErrSegmentLength      = errors.New("SimpleRTree: number of coordinates in FlatSegments is not a multiple of 4")
+	ErrNaNCoordinate      = errors.New("SimpleRTree: NaN coordinate")
+	ErrInfCoordinate      = errors.New("SimpleRTree: infinite coordinate")
+	ErrIDsLength          = errors.New("SimpleRTree: number of ids does not match number of points")
+	ErrInvalidFormat      = errors.New("SimpleRTree: invalid serialized tree")
+	ErrUnsupportedVersion = errors.New("SimpleRTree: unsupported serialization version")
+	ErrMetricMismatch     = errors.New("SimpleRTree: serialized tree was written with a different metric")
+)
+
+// NewWithOptionsErr returns an instance of an RTree with given options o, or ErrInvalidOptions if they are not valid
+func NewWithOptionsErr(o Options) (*SimpleRTree, error) {
+	r := &SimpleRTree{
+		options: o,
+	}
+	if o.MAX_ENTRIES > MAX_POSSIBLE_SIZE {
+		return nil, fmt.Errorf("%w: cannot exceed %d for size", ErrInvalidOptions, MAX_POSSIBLE_SIZE)
+	}
+	if o.MAX_ENTRIES < 0 || o.MAX_ENTRIES == 1 {
+		return nil, fmt.Errorf("%w: MAX_ENTRIES must be at least 2, got %d", ErrInvalidOptions, o.MAX_ENTRIES)
+	}
+	if o.TreeType != STR && o.TreeType != HILBERT && o.TreeType != ZORDER {
+		return nil, fmt.Errorf("%w: unknown tree type %d", ErrInvalidOptions, o.TreeType)
+	}
+	if !(o.CompactThreshold >= 0 && o.CompactThreshold <= 1) {
+		return nil, fmt.Errorf("%w: CompactThreshold must be between 0 and 1, got %f", ErrInvalidOptions, o.CompactThreshold)
+	}
+	if b := o.WorldBBox; b != nil && !(b[0] <= b[2] && b[1] <= b[3] && finite(b[0], b[1], b[2], b[3])) {
+		return nil, fmt.Errorf("%w: WorldBBox %v is not a finite bbox", ErrInvalidOptions, *b)
+	}
+	if o.Geodesic && o.Metric != nil {
+		return nil, fmt.Errorf("%w: Geodesic cannot be combined with Metric", ErrInvalidOptions)
+	}
+	if w, ok := o.Metric.(WeightedEuclidean); ok && !(w.WX > 0 && w.WY > 0 && finite(w.WX, w.WY)) {
+		return nil, fmt.Errorf("%w: weights of WeightedEuclidean must be positive, got %v", ErrInvalidOptions, w)
+	}
+	if o.MAX_ENTRIES == 0 {
+		r.options.MAX_ENTRIES = DEFAULT_MAX_ENTRIES
+	}
+	return r, nil
+}
+
+// LoadErr works as Load but returns an error instead of exiting the process.
+// Points are checked with FlatPoints.Validate before building the tree
+func (r *SimpleRTree) LoadErr(points FlatPoints) (*SimpleRTree, error) {
+	if err := points.Validate(); err != nil {
+		return r, err
+	}
+	return r, r.loadErr(points, nil, false)
+}
+
+// LoadSortedArrayErr works as LoadSortedArray but returns an error instead of exiting the process
+func (r *SimpleRTree) LoadSortedArrayErr(points FlatPoints) (*SimpleRTree, error) {
+	if err := points.Validate(); err != nil {
+		return r, err
+	}
+	return r, r.loadErr(points, nil, true)
+}
+
+// LoadWithIDsErr works as LoadWithIDs but returns an error instead of exiting the process
+func (r *SimpleRTree) LoadWithIDsErr(points FlatPoints, ids []int) (*SimpleRTree, error) {
+	if err := points.Validate(); err != nil {
+		return r, err
+	}
+	if ids == nil {
+		ids = identityIDs(points.Len())
+	}
+	return r, r.loadErr(points, ids, false)
+}
+
+// ValidationError reports the first invalid point found by FlatPoints.Validate, or bbox or segment found by
+// FlatBBoxes.Validate and FlatSegments.Validate.
+// Kind is one of ErrOddLength, ErrNaNCoordinate or ErrInfCoordinate, ErrBBoxLength or ErrInvertedBBox for bboxes
+// and ErrSegmentLength for segments
+type ValidationError struct {
+	Index   int // index of the point (bbox or segment), for odd length it is the index of the incomplete point
+	Kind    error
+	element string // "bbox" or "segment", empty for points
+}
+
+func (e *ValidationError) Error() string {
+	if e.element != "" {
+		return fmt.Sprintf("%s: %s %d", e.Kind.Error(), e.element, e.Index)
+	}
+	return fmt.Sprintf("%s: point %d", e.Kind.Error(), e.Index)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Kind
+}
+
+// Validate checks that points can be used to build a tree. That is, the array has even length and
+// all coordinates are finite. In case of error it returns a *ValidationError with the first offending point.
+// It is called by LoadErr and similar, but it can be used standalone
+//  if err := fp.Validate(); err != nil {
+//    var ve *SimpleRTree.ValidationError
+//    errors.As(err, &ve)
+//    fmt.Println(ve.Index, ve.Kind)
+//  }
+func (fp FlatPoints) Validate() error {
+	for i, c := range fp {
+		if math.IsNaN(c) {
+			return &ValidationError{Index: i / 2, Kind: ErrNaNCoordinate}
+		}
+		if math.IsInf(c, 0) {
+			return &ValidationError{Index: i / 2, Kind: ErrInfCoordinate}
+		}
+	}
+	if len(fp)%2 != 0 {
+		return &ValidationError{Index: fp.Len(), Kind: ErrOddLength}
+	}
+	return nil
+}
+
+// Validate checks that bboxes can be used to build a BBoxRTree. That is, the length of the array is a multiple of 4,
+// all coordinates are finite and min coordinates are not greater than max coordinates.
+// In case of error it returns a *ValidationError, as in FlatPoints.Validate
+func (fb FlatBBoxes) Validate() error {
+	for i, c := range fb {
+		if math.IsNaN(c) {
+			return &ValidationError{Index: i / 4, Kind: ErrNaNCoordinate, element: "bbox"}
+		}
+		if math.IsInf(c, 0) {
+			return &ValidationError{Index: i / 4, Kind: ErrInfCoordinate, element: "bbox"}
+		}
+	}
+	for i := 0; i < fb.Len(); i++ {
+		minX, minY, maxX, maxY := fb.GetBBoxAt(i)
+		if minX > maxX || minY > maxY {
+			return &ValidationError{Index: i, Kind: ErrInvertedBBox, element: "bbox"}
+		}
+	}
+	if len(fb)%4 != 0 {
+		return &ValidationError{Index: fb.Len(), Kind: ErrBBoxLength, element: "bbox"}
+	}
+	return nil
+}
+
+// Validate checks that segments can be used to build a SegmentRTree. That is, the length of the array is a multiple of 4
+// and all coordinates are finite. In case of error it returns a *ValidationError, as in FlatPoints.Validate
+func (fs FlatSegments) Validate() error {
+	for i, c := range fs {
+		if math.IsNaN(c) {
+			return &ValidationError{Index: i / 4, Kind: ErrNaNCoordinate, element: "segment"}
+		}
+		if math.IsInf(c, 0) {
+			return &ValidationError{Index: i / 4, Kind: ErrInfCoordinate, element: "segment"}
+		}
+	}
+	if len(fs)%4 != 0 {
+		return &ValidationError{Index: fs.Len(), Kind: ErrSegmentLength, element: "segment"}
+	}
+	return nil
+}
+
+func finite(values ...float64) bool {
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func identityIDs(n int) []int {
+	ids := make([]int, n)
+	for i := range ids {
+		ids[i] = i
+	}
+	return ids
+}
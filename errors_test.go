@@ -0,0 +1,129 @@
+package SimpleRTree
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestNewWithOptionsErr(t *testing.T) {
+	testCases := []struct {
+		options  Options
+		expected error
+	}{
+		{Options{}, nil},
+		{Options{MAX_ENTRIES: 4, TreeType: HILBERT}, nil},
+		{Options{MAX_ENTRIES: MAX_POSSIBLE_SIZE + 1}, ErrInvalidOptions},
+		{Options{MAX_ENTRIES: -1}, ErrInvalidOptions},
+		{Options{MAX_ENTRIES: 1}, ErrInvalidOptions},
+		{Options{TreeType: 10}, ErrInvalidOptions},
+		{Options{TreeType: HILBERT, WorldBBox: &[4]float64{0, 0, 10, 10}}, nil},
+		{Options{WorldBBox: &[4]float64{10, 0, 0, 10}}, ErrInvalidOptions},
+		{Options{WorldBBox: &[4]float64{0, 0, math.Inf(1), 10}}, ErrInvalidOptions},
+		{Options{Metric: Manhattan{}}, nil},
+		{Options{Metric: WeightedEuclidean{WX: 1, WY: 4}}, nil},
+		{Options{Metric: WeightedEuclidean{WX: 1}}, ErrInvalidOptions},
+		{Options{Metric: WeightedEuclidean{WX: math.NaN(), WY: 1}}, ErrInvalidOptions},
+		{Options{Geodesic: true, Metric: Chebyshev{}}, ErrInvalidOptions},
+	}
+	for _, tc := range testCases {
+		r, err := NewWithOptionsErr(tc.options)
+		if tc.expected == nil {
+			assert.NoError(t, err)
+			assert.NotNil(t, r)
+		} else {
+			assert.True(t, errors.Is(err, tc.expected), "Expected %v got %v", tc.expected, err)
+		}
+	}
+	assert.Panics(t, func() {
+		NewWithOptions(Options{MAX_ENTRIES: MAX_POSSIBLE_SIZE + 1})
+	})
+}
+
+func TestSimpleRTree_LoadErr(t *testing.T) {
+	testCases := []struct {
+		points   FlatPoints
+		expected error
+	}{
+		{FlatPoints{0, 0, 1, 1}, nil},
+		{FlatPoints{}, nil},
+		{FlatPoints{0, 0, 1}, ErrOddLength},
+		{FlatPoints{0, 0, 1, math.NaN()}, ErrNaNCoordinate},
+		{FlatPoints{0, 0, math.Inf(-1), 1}, ErrInfCoordinate},
+	}
+	for _, tc := range testCases {
+		_, err := New().LoadErr(tc.points)
+		if tc.expected == nil {
+			assert.NoError(t, err)
+		} else {
+			assert.True(t, errors.Is(err, tc.expected), "Expected %v got %v", tc.expected, err)
+		}
+	}
+
+	r, err := New().LoadErr(FlatPoints{0, 0, 1, 1})
+	assert.NoError(t, err)
+	_, err = r.LoadErr(FlatPoints{0, 0, 1, 1})
+	assert.True(t, errors.Is(err, ErrAlreadyBuilt))
+
+	_, err = New().LoadWithIDsErr(FlatPoints{0, 0, 1, 1}, []int{1})
+	assert.True(t, errors.Is(err, ErrIDsLength))
+	_, err = NewTree[string]().LoadErr(FlatPoints{0, 0, 1, 1}, []string{"origin"})
+	assert.True(t, errors.Is(err, ErrIDsLength))
+	_, err = NewTree[string]().LoadErr(FlatPoints{0, 0, 1, math.NaN()}, []string{"origin", "corner"})
+	assert.True(t, errors.Is(err, ErrNaNCoordinate))
+	tr, err := NewTree[string]().LoadErr(FlatPoints{0, 0, 1, 1}, []string{"origin", "corner"})
+	assert.NoError(t, err)
+	value, _, _, _ := tr.FindNearest(2, 2)
+	assert.Equal(t, "corner", value)
+
+	r, err = New().LoadSortedArrayErr(FlatPoints{0, 0, 1, 1})
+	assert.NoError(t, err)
+	x, y, _ := r.FindNearestPoint(2, 2)
+	assert.Equal(t, 1., x)
+	assert.Equal(t, 1., y)
+}
+
+func TestFlatPoints_Validate(t *testing.T) {
+	testCases := []struct {
+		points FlatPoints
+		index  int
+		kind   error
+	}{
+		{FlatPoints{0, 0, 1}, 1, ErrOddLength},
+		{FlatPoints{0, 0, 1, 1, math.NaN(), 2}, 2, ErrNaNCoordinate},
+		{FlatPoints{0, math.Inf(1), 1, 1}, 0, ErrInfCoordinate},
+		{FlatPoints{0, 0, 1, 1, 2, math.NaN(), 3}, 2, ErrNaNCoordinate},
+	}
+	for _, tc := range testCases {
+		err := tc.points.Validate()
+		var ve *ValidationError
+		assert.True(t, errors.As(err, &ve))
+		assert.Equal(t, tc.index, ve.Index)
+		assert.Equal(t, tc.kind, ve.Kind)
+		assert.True(t, errors.Is(err, tc.kind))
+	}
+	assert.NoError(t, FlatPoints{0, 0, 1, 1}.Validate())
+}
+
+func TestFlatBBoxes_Validate(t *testing.T) {
+	testCases := []struct {
+		boxes FlatBBoxes
+		index int
+		kind  error
+	}{
+		{FlatBBoxes{0, 0, 1, 1, 2}, 1, ErrBBoxLength},
+		{FlatBBoxes{0, 0, 1, 1, 2, math.NaN(), 3, 3}, 1, ErrNaNCoordinate},
+		{FlatBBoxes{0, 0, math.Inf(1), 1}, 0, ErrInfCoordinate},
+		{FlatBBoxes{0, 0, 1, 1, 2, 2, 3, 1}, 1, ErrInvertedBBox},
+	}
+	for _, tc := range testCases {
+		err := tc.boxes.Validate()
+		var ve *ValidationError
+		assert.True(t, errors.As(err, &ve))
+		assert.Equal(t, tc.index, ve.Index)
+		assert.Equal(t, tc.kind, ve.Kind)
+		assert.True(t, errors.Is(err, tc.kind))
+	}
+	assert.NoError(t, FlatBBoxes{0, 0, 1, 1, 2, 2, 2, 2}.Validate())
+}
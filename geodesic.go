@@ -0,0 +1,70 @@
+package SimpleRTree
+
+import "math"
+
+// earth_radius is the mean radius of the Earth in meters, used by Options.Geodesic
+const earth_radius = 6371008.8
+
+const degrees = math.Pi / 180
+
+// haversine is the metric of Options.Geodesic. Points are longitude, latitude in degrees
+// and distances are great-circle distances in meters, squared
+type haversine struct{}
+
+func (haversine) Distance(x1, y1, x2, y2 float64) float64 {
+	d := haversineDistance(x1, y1, x2, y2)
+	return d * d
+}
+
+// BBoxDistances finds the closest point of the bbox. If the meridian of x crosses the bbox, it is on that meridian.
+// Otherwise it is on the side of the bbox with the closest longitude, going across the antimeridian if it is shorter,
+// since for a given latitude distance grows with the difference of longitude.
+// Along that side, the closest point is the foot of the great circle through x, y perpendicular to it, clamped to the bbox.
+// There is no upper bound
+func (haversine) BBoxDistances(minLon, minLat, maxLon, maxLat, x, y float64) (mind, maxd float64) {
+	var dLon float64
+	if mod360(x-minLon) > maxLon-minLon {
+		dLon = math.Min(mod360(minLon-x), mod360(x-maxLon))
+	}
+	var lat float64
+	if dLon == 0 {
+		lat = math.Max(minLat, math.Min(maxLat, y))
+	} else {
+		// cos of the distance to a point of the side at latitude l is sin(y) sin(l) + cos(y) cos(l) cos(dLon),
+		// which is largest at l = foot and decreases away from it
+		sinY, cosY := math.Sincos(y * degrees)
+		cosLon := math.Cos(dLon * degrees)
+		foot := math.Atan2(sinY, cosY*cosLon) / degrees
+		lat = foot
+		if foot < minLat || foot > maxLat {
+			cosDistance := func(l float64) float64 {
+				sinL, cosL := math.Sincos(l * degrees)
+				return sinY*sinL + cosY*cosL*cosLon
+			}
+			lat = minLat
+			if cosDistance(maxLat) > cosDistance(minLat) {
+				lat = maxLat
+			}
+		}
+	}
+	d := haversineDistance(x, y, x+dLon, lat)
+	// rounding of dLon could make the bound slightly larger than the distance to a point on the side of the bbox
+	return d * d * (1 - 1e-9), math.Inf(1)
+}
+
+// haversineDistance returns the great-circle distance in meters between two points given as longitude, latitude in degrees
+func haversineDistance(lon1, lat1, lon2, lat2 float64) float64 {
+	sinLat := math.Sin((lat2 - lat1) * degrees / 2)
+	sinLon := math.Sin((lon2 - lon1) * degrees / 2)
+	a := sinLat*sinLat + math.Cos(lat1*degrees)*math.Cos(lat2*degrees)*sinLon*sinLon
+	return 2 * earth_radius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// mod360 returns a in [0, 360)
+func mod360(a float64) float64 {
+	a = math.Mod(a, 360)
+	if a < 0 {
+		a += 360
+	}
+	return a
+}
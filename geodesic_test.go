@@ -0,0 +1,146 @@
+package SimpleRTree
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestHaversineDistance(t *testing.T) {
+	testCases := []struct {
+		name                   string
+		lon1, lat1, lon2, lat2 float64
+		expected               float64
+	}{
+		{"same point", 2.35, 48.85, 2.35, 48.85, 0},
+		{"one degree of latitude", 0, 0, 0, 1, earth_radius * math.Pi / 180},
+		{"antipodes", 0, 0, 180, 0, earth_radius * math.Pi},
+		{"antimeridian", 179.9, 0, -179.9, 0, earth_radius * 0.2 * math.Pi / 180},
+		{"over the pole", 0, 89, 180, 89, earth_radius * 2 * math.Pi / 180},
+	}
+	for _, tc := range testCases {
+		assert.InDelta(t, tc.expected, haversineDistance(tc.lon1, tc.lat1, tc.lon2, tc.lat2), 1e-6, tc.name)
+	}
+	// London - Paris
+	assert.InDelta(t, 343.5e3, haversineDistance(-0.1278, 51.5074, 2.3522, 48.8566), 1e3)
+}
+
+func TestHaversine_BBoxDistance(t *testing.T) {
+	const samples = 1000
+	m := haversine{}
+	for n := 0; n < 1000; n++ {
+		minX, maxX := sortFloats(rand.Float64()*360-180, rand.Float64()*360-180)
+		minY, maxY := sortFloats(rand.Float64()*180-90, rand.Float64()*180-90)
+		if n%2 == 0 {
+			// small bboxes, as for nodes close to the leaves
+			maxX, maxY = math.Min(180, minX+rand.Float64()), math.Min(90, minY+rand.Float64())
+		}
+		bbox := rVectorBBox{minX, minY, maxX, maxY}
+		x, y := rand.Float64()*360-180, rand.Float64()*180-90
+		if n%5 == 0 {
+			// close to the antimeridian
+			x = 180 - rand.Float64()*2
+			if n%10 == 0 {
+				x = -x
+			}
+		}
+		d, maxd := m.BBoxDistances(minX, minY, maxX, maxY, x, y)
+		assert.True(t, math.IsInf(maxd, 1))
+		if minX <= x && x <= maxX && minY <= y && y <= maxY {
+			assert.Equal(t, 0., d)
+			continue
+		}
+		// closest point is on the border of the bbox
+		closest := math.Inf(1)
+		for i := 0; i <= samples; i++ {
+			lon := minX + (maxX-minX)*float64(i)/samples
+			lat := minY + (maxY-minY)*float64(i)/samples
+			for _, p := range [4][2]float64{{lon, minY}, {lon, maxY}, {minX, lat}, {maxX, lat}} {
+				closest = math.Min(closest, m.Distance(x, y, p[0], p[1]))
+			}
+		}
+		assert.True(t, d <= closest, "bound %f larger than distance %f for %v from %f, %f", d, closest, bbox, x, y)
+		// samples are at most step meters away from the closest point
+		step := earth_radius * (maxX - minX + maxY - minY) * degrees / samples
+		assert.InDelta(t, math.Sqrt(closest), math.Sqrt(d), step+1, "bound is not tight for %v from %f, %f", bbox, x, y)
+	}
+}
+
+func TestSimpleRTree_Geodesic(t *testing.T) {
+	const size = 3000
+	points := make(FlatPoints, 0, 2*size)
+	for i := 0; i < size; i++ {
+		points = append(points, rand.Float64()*360-180, math.Asin(rand.Float64()*2-1)/degrees)
+	}
+	queries := FlatPoints{179.99, 0, -179.99, 10, 180, -45, -180, 60, 0, 89.9, 120, -89.9, 0, 90}
+	for i := 0; i < 50; i++ {
+		queries = append(queries, rand.Float64()*360-180, rand.Float64()*180-90)
+	}
+	const radius = 500e3
+	for _, options := range []Options{{Geodesic: true}, {Geodesic: true, TreeType: HILBERT}, {Geodesic: true, MAX_ENTRIES: 4}} {
+		options.CopyPoints = true
+		r := NewWithOptions(options).Load(points)
+		for i := 0; i < queries.Len(); i++ {
+			x, y := queries.GetPointAt(i)
+			x1, y1, d1 := r.FindNearestPoint(x, y)
+			x2, y2, d2 := points.linearMetricClosestPoint(haversine{}, x, y)
+			assert.Equal(t, x2, x1)
+			assert.Equal(t, y2, y1)
+			assert.Equal(t, d2, d1)
+
+			assert.Equal(t, points.linearMetricKClosestPoints(haversine{}, x, y, 7), r.FindKNearestPoints(x, y, 7, nil))
+			assert.Equal(t, points.linearMetricPointsWithin(haversine{}, x, y, radius*radius), r.FindPointsWithin(x, y, radius*radius, FlatPoints{}).sorted())
+		}
+		out, distances := r.FindNearestPoints(queries, nil, []float64{})
+		for i := 0; i < queries.Len(); i++ {
+			x1, y1, d1 := r.FindNearestPoint(queries.GetPointAt(i))
+			assert.Equal(t, FlatPoints{x1, y1}, out[2*i:2*i+2])
+			assert.Equal(t, d1, distances[i])
+		}
+	}
+
+	// Across the antimeridian and close to the poles the closest points are not the closest in degrees
+	r := NewWithOptions(Options{Geodesic: true}).Load(FlatPoints{179.99, 0, -179.9, 0, 0, 89, 100, 80})
+	x1, y1, _ := r.FindNearestPoint(-179.99, 0)
+	assert.Equal(t, FlatPoints{179.99, 0}, FlatPoints{x1, y1})
+	x1, y1, d1 := r.FindNearestPoint(180, 89)
+	assert.Equal(t, FlatPoints{0, 89}, FlatPoints{x1, y1})
+	assert.InDelta(t, earth_radius*2*math.Pi/180, math.Sqrt(d1), 1e-6)
+	_, _, _, found := r.FindNearestPointWithin(-179.99, 0, 2000*2000)
+	assert.False(t, found)
+	_, _, _, found = r.FindNearestPointWithin(-179.99, 0, 2300*2300)
+	assert.True(t, found)
+
+	// Search is not affected
+	assert.Equal(t, FlatPoints{-179.9, 0}, r.Search(-180, -1, -179, 1, nil))
+
+	d := NewDynamicWithOptions(Options{Geodesic: true})
+	for i := 0; i < 1000; i++ {
+		d.Insert(points.GetPointAt(i))
+	}
+	for i := 0; i < queries.Len(); i++ {
+		x, y := queries.GetPointAt(i)
+		x1, y1, d1 := d.FindNearestPoint(x, y)
+		x2, y2, d2 := points[0:2000].linearMetricClosestPoint(haversine{}, x, y)
+		assert.Equal(t, FlatPoints{x2, y2}, FlatPoints{x1, y1})
+		assert.Equal(t, d2, d1)
+		assert.Equal(t, points[0:2000].linearMetricKClosestPoints(haversine{}, x, y, 7), d.FindKNearestPoints(x, y, 7, nil))
+		assert.Equal(t, points[0:2000].linearMetricPointsWithin(haversine{}, x, y, radius*radius), d.FindPointsWithin(x, y, radius*radius, FlatPoints{}).sorted())
+	}
+
+	assert.Panics(t, func() { NewBBoxRTreeWithOptions(Options{Geodesic: true}) })
+}
+
+func BenchmarkSimpleRTree_FindNearestPointGeodesic(b *testing.B) {
+	const size = 1000000
+	points := make(FlatPoints, 0, 2*size)
+	for i := 0; i < size; i++ {
+		points = append(points, rand.Float64()*360-180, math.Asin(rand.Float64()*2-1)/degrees)
+	}
+	r := NewWithOptions(Options{UnsafeConcurrencyMode: true, Geodesic: true}).Load(points)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.FindNearestPoint(rand.Float64()*360-180, rand.Float64()*180-90)
+	}
+}
@@ -2,17 +2,64 @@ package SimpleRTree
 
 import "math"
 
+// GeoHash returns the position of the coordinates in a Z-order (Morton) curve.
 // Original code from https://mmcloughlin.com/posts/geohash-assembly
 func GeoHash(lat, lng float64) uint64 {
 	return interleave(hashQuantize(lat, lng))
 }
 
+// HilbertHash returns the position of the coordinates in a Hilbert curve over the same grid as GeoHash.
+// Consecutive cells in a Hilbert curve are always adjacent, which gives better locality than Z-order
+func HilbertHash(lat, lng float64) uint64 {
+	return hilbertIndex(hashQuantize(lat, lng))
+}
+
 func hashQuantize(lat, lng float64) (lat32 uint32, lng32 uint32) {
 	lat32 = uint32(math.Ldexp((lat+90.0)/180.0, 32))
 	lng32 = uint32(math.Ldexp((lng+180.0)/360.0, 32))
 	return
 }
 
+// curveGrid maps points inside a bbox to the 32 bit grid used by the space filling curves.
+// Unlike hashQuantize it works for any planar coordinates, points outside of the bbox are clamped to its border
+type curveGrid struct {
+	minX, minY     float64
+	scaleX, scaleY float64
+}
+
+// newCurveGrid returns the grid for the bbox minX, minY, maxX, maxY. A degenerate axis (min == max) maps to 0
+func newCurveGrid(minX, minY, maxX, maxY float64) curveGrid {
+	return curveGrid{
+		minX:   minX,
+		minY:   minY,
+		scaleX: gridScale(minX, maxX),
+		scaleY: gridScale(minY, maxY),
+	}
+}
+
+// gridScale works with halves so that max - min cannot overflow for huge extents
+func gridScale(min, max float64) float64 {
+	if !(max > min) {
+		return 0
+	}
+	return math.MaxUint32 / (max/2 - min/2)
+}
+
+func (g curveGrid) quantize(x, y float64) (uint32, uint32) {
+	return quantizeAxis(x/2-g.minX/2, g.scaleX), quantizeAxis(y/2-g.minY/2, g.scaleY)
+}
+
+func quantizeAxis(halfOffset, scale float64) uint32 {
+	v := halfOffset * scale
+	if !(v > 0) {
+		return 0
+	}
+	if v >= math.MaxUint32 {
+		return math.MaxUint32
+	}
+	return uint32(v)
+}
+
 func spread(x uint32) uint64 {
 	X := uint64(x)
 	X = (X | (X << 16)) & 0x0000ffff0000ffff
@@ -27,8 +74,51 @@ func interleave(x, y uint32) uint64 {
 	return spread(x) | (spread(y) << 1)
 }
 
+// hilbertIndex computes the index of x, y in a Hilbert curve of order 32 without branches.
+// It is a 32 bit version of the prefix scan algorithm in http://threadlocalmutex.com/?p=126
+// Each round combines the transformations of twice as many bits as the previous one.
+func hilbertIndex(x, y uint32) uint64 {
+	const mask = 0xFFFFFFFF
+	X := uint64(x)
+	Y := uint64(y)
+	var A, B, C, D uint64
+	// Initial prefix scan round, prime with x and y
+	{
+		a := X ^ Y
+		b := mask ^ a
+		c := mask ^ (X | Y)
+		d := X & (Y ^ mask)
+		A = a | (b >> 1)
+		B = (a >> 1) ^ a
+		C = ((c >> 1) ^ (b & (d >> 1))) ^ c
+		D = ((a & (c >> 1)) ^ (d >> 1)) ^ d
+	}
+	for shift := uint(2); shift < 16; shift *= 2 {
+		a, b, c, d := A, B, C, D
+		A = (a & (a >> shift)) ^ (b & (b >> shift))
+		B = (a & (b >> shift)) ^ (b & ((a ^ b) >> shift))
+		C ^= (a & (c >> shift)) ^ (b & (d >> shift))
+		D ^= (b & (c >> shift)) ^ ((a ^ b) & (d >> shift))
+	}
+	// Final round, A and B are no longer needed
+	{
+		a, b, c, d := A, B, C, D
+		C ^= (a & (c >> 16)) ^ (b & (d >> 16))
+		D ^= (b & (c >> 16)) ^ ((a ^ b) & (d >> 16))
+	}
+	// Undo transformation prefix scan
+	a := C ^ (C >> 1)
+	b := D ^ (D >> 1)
+	// Recover index bits
+	i0 := X ^ Y
+	i1 := b | (mask ^ (i0 | a))
+	return (spread(uint32(i1)) << 1) | spread(uint32(i0))
+}
+
+// GeoHashSorter sorts points by the given hashes, either from GeoHash or HilbertHash
 type GeoHashSorter struct {
 	points FlatPoints
+	ids    []int
 	hashes []uint64
 }
 
@@ -39,6 +129,7 @@ func (s GeoHashSorter) Less(i, j int) bool {
 
 func (s GeoHashSorter) Swap(i, j int) {
 	s.points.Swap(i, j)
+	swapIDs(s.ids, i, j)
 	s.hashes[i], s.hashes[j] = s.hashes[j], s.hashes[i]
 }
 
@@ -0,0 +1,107 @@
+package SimpleRTree
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestHilbertIndex(t *testing.T) {
+	// Cells of a coarse grid sorted by their hilbert index must be adjacent
+	const order = 4
+	const side = 1 << order
+	type cell struct {
+		x, y  int
+		index uint64
+	}
+	cells := make([]cell, 0, side*side)
+	for i := 0; i < side; i++ {
+		for j := 0; j < side; j++ {
+			index := hilbertIndex(uint32(i)<<(32-order), uint32(j)<<(32-order))
+			cells = append(cells, cell{i, j, index})
+		}
+	}
+	sort.Slice(cells, func(i, j int) bool {
+		return cells[i].index < cells[j].index
+	})
+	for i := 1; i < len(cells); i++ {
+		dx := cells[i].x - cells[i-1].x
+		dy := cells[i].y - cells[i-1].y
+		assert.Equal(t, 1, dx*dx+dy*dy, "Consecutive cells %v %v", cells[i-1], cells[i])
+		assert.True(t, cells[i].index > cells[i-1].index, "Indexes are unique")
+	}
+	assert.Equal(t, uint64(0), hilbertIndex(0, 0))
+}
+
+func TestHilbertIndexIsBijective(t *testing.T) {
+	seen := map[uint64]bool{}
+	for i := 0; i < 10000; i++ {
+		x, y := rand.Uint32(), rand.Uint32()
+		index := hilbertIndex(x, y)
+		assert.False(t, seen[index])
+		seen[index] = true
+	}
+}
+
+func TestCurveGrid(t *testing.T) {
+	g := newCurveGrid(1e6, -5e6, 2e6, 5e6)
+	x, y := g.quantize(1e6, -5e6)
+	assert.Equal(t, uint32(0), x)
+	assert.Equal(t, uint32(0), y)
+	x, y = g.quantize(2e6, 5e6)
+	assert.Equal(t, uint32(math.MaxUint32), x)
+	assert.Equal(t, uint32(math.MaxUint32), y)
+	x, y = g.quantize(1.5e6, 0)
+	assert.InDelta(t, math.MaxUint32/2, float64(x), 2)
+	assert.InDelta(t, math.MaxUint32/2, float64(y), 2)
+
+	// Points outside are clamped
+	x, y = g.quantize(0, 1e7)
+	assert.Equal(t, uint32(0), x)
+	assert.Equal(t, uint32(math.MaxUint32), y)
+
+	// Degenerate and huge extents
+	g = newCurveGrid(3, -math.MaxFloat64, 3, math.MaxFloat64)
+	x, y = g.quantize(3, 0)
+	assert.Equal(t, uint32(0), x)
+	assert.InDelta(t, math.MaxUint32/2, float64(y), 2)
+}
+
+func TestSimpleRTree_SortForLoad(t *testing.T) {
+	const size = 5000
+	points := make(FlatPoints, 0, 2*size)
+	for i := 0; i < size; i++ {
+		points = append(points, rand.Float64()*1000-300, rand.Float64()*10)
+	}
+	world := [4]float64{-1000, -1000, 1000, 1000}
+	for _, options := range []Options{{}, {TreeType: HILBERT}, {TreeType: ZORDER}, {TreeType: HILBERT, WorldBBox: &world}} {
+		options.CopyPoints = true
+		r := NewWithOptions(options).Load(points)
+		sorted := append(FlatPoints{}, points...)
+		NewWithOptions(options).SortForLoad(sorted)
+		rS := NewWithOptions(options).LoadSortedArray(sorted)
+		if options.TreeType != STR {
+			assert.Equal(t, r.points, rS.points, "Points are in the order of the curve of the tree")
+		}
+		for i := 0; i < 100; i++ {
+			x, y := rand.Float64()*1000-300, rand.Float64()*10
+			x1, y1, _ := r.FindNearestPoint(x, y)
+			x2, y2, _ := rS.FindNearestPoint(x, y)
+			assert.Equal(t, FlatPoints{x1, y1}, FlatPoints{x2, y2})
+		}
+	}
+}
+
+func Benchmark_GeoHash(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		_ = GeoHash(rand.Float64(), rand.Float64())
+	}
+}
+
+func Benchmark_HilbertHash(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		_ = HilbertHash(rand.Float64(), rand.Float64())
+	}
+}
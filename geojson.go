@@ -0,0 +1,116 @@
+package SimpleRTree
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+)
+
+// GeoJSONOptions selects what WriteGeoJSON writes
+type GeoJSONOptions struct {
+	Points   bool // Set this parameter to true to write the points of the tree as Point features, in addition to the bboxes of the nodes. Deleted points are skipped
+	MaxDepth int  // Nodes deeper than MaxDepth are not written, the root has depth 0. If 0 every node is written
+}
+
+// WriteGeoJSON writes the tree as a GeoJSON FeatureCollection, which is useful to inspect how the points were packed.
+// Every node is a Polygon feature with its bbox and the properties "depth", "type" ("default" or "preleaf") and "children".
+// Points have properties "depth", "type" ("point") and "id", which is the position of the point if the tree was loaded without ids.
+// Features are written as the tree is traversed, so the output is never held in memory
+//
+//	f, _ := os.Create("tree.geojson")
+//	defer f.Close()
+//	err := r.WriteGeoJSON(f, SimpleRTree.GeoJSONOptions{Points: true})
+func (r *SimpleRTree) WriteGeoJSON(w io.Writer, opts GeoJSONOptions) error {
+	g := geoJSONWriter{w: bufio.NewWriter(w), opts: opts, buf: make([]byte, 0, 256)}
+	g.w.WriteString(`{"type":"FeatureCollection","features":[`)
+	if len(r.nodes) > 0 {
+		r.writeGeoJSONNode(&g, 0, 0)
+	}
+	g.w.WriteString("\n]}\n")
+	return g.w.Flush()
+}
+
+func (r *SimpleRTree) writeGeoJSONNode(g *geoJSONWriter, nodeIndex int, depth int) {
+	n := r.nodes[nodeIndex]
+	nodeTypeName := "default"
+	if n.nodeType == preleaf_node {
+		nodeTypeName = "preleaf"
+	}
+	b := g.startFeature()
+	b = append(b, `{"type":"Polygon","coordinates":[[`...)
+	corners := [5][2]int{{0, 1}, {2, 1}, {2, 3}, {0, 3}, {0, 1}}
+	for i, c := range corners {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendCoordinates(b, n.BBox[c[0]], n.BBox[c[1]])
+	}
+	b = append(b, `]]},"properties":{"depth":`...)
+	b = strconv.AppendInt(b, int64(depth), 10)
+	b = append(b, `,"type":"`...)
+	b = append(b, nodeTypeName...)
+	b = append(b, `","children":`...)
+	b = strconv.AppendInt(b, int64(n.nChildren), 10)
+	g.endFeature(b)
+
+	if depth == g.opts.MaxDepth && g.opts.MaxDepth > 0 {
+		return
+	}
+	first := int(n.firstChild)
+	for i := first; i < first+int(n.nChildren); i++ {
+		if n.nodeType == default_node {
+			r.writeGeoJSONNode(g, i, depth+1)
+		} else if g.opts.Points && (r.deleted == nil || !r.deleted[i]) {
+			r.writeGeoJSONPoint(g, i, depth+1)
+		}
+	}
+}
+
+func (r *SimpleRTree) writeGeoJSONPoint(g *geoJSONWriter, index int, depth int) {
+	id := index
+	if r.ids != nil {
+		id = r.ids[index]
+	}
+	x, y := r.points.GetPointAt(index)
+	b := g.startFeature()
+	b = append(b, `{"type":"Point","coordinates":`...)
+	b = appendCoordinates(b, x, y)
+	b = append(b, `},"properties":{"depth":`...)
+	b = strconv.AppendInt(b, int64(depth), 10)
+	b = append(b, `,"type":"point","id":`...)
+	b = strconv.AppendInt(b, int64(id), 10)
+	g.endFeature(b)
+}
+
+// geoJSONWriter writes features one at a time, reusing buf to format them
+type geoJSONWriter struct {
+	w       *bufio.Writer
+	opts    GeoJSONOptions
+	buf     []byte
+	started bool
+}
+
+// startFeature returns the buffer with the beginning of a feature, up to its geometry
+func (g *geoJSONWriter) startFeature() []byte {
+	b := g.buf[0:0]
+	if g.started {
+		b = append(b, ',')
+	}
+	g.started = true
+	return append(b, "\n"+`{"type":"Feature","geometry":`...)
+}
+
+// endFeature closes the properties and the feature and writes it
+func (g *geoJSONWriter) endFeature(b []byte) {
+	b = append(b, "}}"...)
+	g.w.Write(b)
+	g.buf = b
+}
+
+func appendCoordinates(b []byte, x, y float64) []byte {
+	b = append(b, '[')
+	b = strconv.AppendFloat(b, x, 'g', -1, 64)
+	b = append(b, ',')
+	b = strconv.AppendFloat(b, y, 'g', -1, 64)
+	return append(b, ']')
+}
@@ -0,0 +1,134 @@
+package SimpleRTree
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+type geoJSONFeature struct {
+	Type     string
+	Geometry struct {
+		Type        string
+		Coordinates json.RawMessage
+	}
+	Properties struct {
+		Depth    int
+		Type     string
+		Children int
+		ID       *int
+	}
+}
+
+func decodeGeoJSON(t *testing.T, b []byte) []geoJSONFeature {
+	var collection struct {
+		Type     string
+		Features []geoJSONFeature
+	}
+	assert.NoError(t, json.Unmarshal(b, &collection))
+	assert.Equal(t, "FeatureCollection", collection.Type)
+	return collection.Features
+}
+
+func TestSimpleRTree_WriteGeoJSON(t *testing.T) {
+	const size = 500
+	points := make(FlatPoints, 2*size)
+	for i := range points {
+		points[i] = rand.Float64()
+	}
+	r := NewWithOptions(Options{CopyPoints: true, MAX_ENTRIES: 4}).LoadWithIDs(points, nil)
+	r.DeleteByID(7)
+
+	var buf bytes.Buffer
+	assert.NoError(t, r.WriteGeoJSON(&buf, GeoJSONOptions{}))
+	features := decodeGeoJSON(t, buf.Bytes())
+	assert.Equal(t, len(r.nodes), len(features))
+	root := features[0]
+	assert.Equal(t, "Feature", root.Type)
+	assert.Equal(t, "Polygon", root.Geometry.Type)
+	assert.Equal(t, 0, root.Properties.Depth)
+	assert.Equal(t, "default", root.Properties.Type)
+	assert.Equal(t, int(r.nodes[0].nChildren), root.Properties.Children)
+	var ring [][][2]float64
+	assert.NoError(t, json.Unmarshal(root.Geometry.Coordinates, &ring))
+	b := r.nodes[0].BBox
+	assert.Equal(t, [][][2]float64{{{b[0], b[1]}, {b[2], b[1]}, {b[2], b[3]}, {b[0], b[3]}, {b[0], b[1]}}}, ring)
+	for _, f := range features {
+		if f.Properties.Type == "preleaf" {
+			assert.Equal(t, r.height-1, f.Properties.Depth)
+		}
+	}
+
+	buf.Reset()
+	assert.NoError(t, r.WriteGeoJSON(&buf, GeoJSONOptions{Points: true}))
+	features = decodeGeoJSON(t, buf.Bytes())
+	assert.Equal(t, len(r.nodes)+size-1, len(features))
+	ids := map[int]bool{}
+	for _, f := range features {
+		if f.Geometry.Type != "Point" {
+			continue
+		}
+		assert.Equal(t, "point", f.Properties.Type)
+		assert.Equal(t, r.height, f.Properties.Depth)
+		var coordinates [2]float64
+		assert.NoError(t, json.Unmarshal(f.Geometry.Coordinates, &coordinates))
+		assert.Equal(t, FlatPoints(coordinates[:]), points.pointsOf([]int{*f.Properties.ID}))
+		ids[*f.Properties.ID] = true
+	}
+	assert.Equal(t, size-1, len(ids))
+	assert.False(t, ids[7], "Deleted point")
+
+	buf.Reset()
+	assert.NoError(t, r.WriteGeoJSON(&buf, GeoJSONOptions{Points: true, MaxDepth: 1}))
+	features = decodeGeoJSON(t, buf.Bytes())
+	assert.Equal(t, 1+int(r.nodes[0].nChildren), len(features))
+
+	buf.Reset()
+	assert.NoError(t, New().WriteGeoJSON(&buf, GeoJSONOptions{}))
+	assert.Equal(t, 0, len(decodeGeoJSON(t, buf.Bytes())))
+
+	assert.Equal(t, errWriter, r.WriteGeoJSON(failingWriter{}, GeoJSONOptions{}))
+}
+
+func TestSimpleRTree_WriteGeoJSONCurveTrees(t *testing.T) {
+	const size = 500
+	points := make(FlatPoints, 2*size)
+	for i := range points {
+		points[i] = rand.Float64()*10 + 5
+	}
+	minX, minY, maxX, maxY := points.extent()
+	expected := [][][2]float64{{{minX, minY}, {maxX, minY}, {maxX, maxY}, {minX, maxY}, {minX, minY}}}
+	for _, treeType := range []TreeType{HILBERT, ZORDER} {
+		r := NewWithOptions(Options{CopyPoints: true, TreeType: treeType}).Load(points)
+		var buf bytes.Buffer
+		assert.NoError(t, r.WriteGeoJSON(&buf, GeoJSONOptions{MaxDepth: 1}))
+		features := decodeGeoJSON(t, buf.Bytes())
+		var ring [][][2]float64
+		assert.NoError(t, json.Unmarshal(features[0].Geometry.Coordinates, &ring))
+		assert.Equal(t, expected, ring, "Root is the extent of the points")
+	}
+}
+
+// extent returns the bbox of the points
+func (fp FlatPoints) extent() (minX, minY, maxX, maxY float64) {
+	minX, minY = fp.GetPointAt(0)
+	maxX, maxY = minX, minY
+	for i := 1; i < fp.Len(); i++ {
+		x, y := fp.GetPointAt(i)
+		minX, minY = math.Min(minX, x), math.Min(minY, y)
+		maxX, maxY = math.Max(maxX, x), math.Max(maxY, y)
+	}
+	return
+}
+
+var errWriter = errors.New("write failed")
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errWriter
+}
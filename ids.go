@@ -0,0 +1,71 @@
+package SimpleRTree
+
+import "math"
+
+// Queries returning ids instead of coordinates. They are meant to be used with trees built with LoadWithIDs,
+// if the tree was loaded without ids the position of the point in the reordered array is returned instead.
+
+// FindNearestPointID works as FindNearestPoint but it also returns the id of the closest point
+//  id, x1, y1, d1 := r.FindNearestPointID(x, y)
+func (r *SimpleRTree) FindNearestPointID(x, y float64) (id int, x1, y1, d1 float64) {
+	id, x1, y1, d1, _ = r.FindNearestPointIDWithin(x, y, math.Inf(1))
+	return
+}
+
+// FindNearestPointIDWithin works as FindNearestPointWithin but it also returns the id of the closest point.
+// In case there is no point within dsquared found will return false
+func (r *SimpleRTree) FindNearestPointIDWithin(x, y, dsquared float64) (id int, x1, y1, d1 float64, found bool) {
+	i, d1, found := r.findNearestPointWithin(x, y, dsquared)
+	if !found {
+		return
+	}
+	x1, y1 = r.points.GetPointAt(i)
+	id = r.id(i)
+	return
+}
+
+// FindKNearestIDs appends to dst the ids of the k closest points in increasing order of distance.
+// See FindKNearestPoints
+func (r *SimpleRTree) FindKNearestIDs(x, y float64, k int, dst []int) []int {
+	return r.FindKNearestIDsWithin(x, y, math.Inf(1), k, dst)
+}
+
+// FindKNearestIDsWithin appends to dst the ids of the k closest points within the distance squared dsquared.
+// See FindKNearestPointsWithin
+func (r *SimpleRTree) FindKNearestIDsWithin(x, y, dsquared float64, k int, dst []int) []int {
+	r.findKNearestPointsWithin(x, y, dsquared, k, func(i int) {
+		dst = append(dst, r.id(i))
+	})
+	return dst
+}
+
+// SearchIDs appends to dst the ids of the points within the given bbox. See Search
+func (r *SimpleRTree) SearchIDs(minX, minY, maxX, maxY float64, dst []int) []int {
+	r.search(rBBox{MinX: minX, MinY: minY, MaxX: maxX, MaxY: maxY}, func(i int) {
+		dst = append(dst, r.id(i))
+	})
+	return dst
+}
+
+// FindIDsWithin appends to dst the ids of the points within the distance squared dsquared. See FindPointsWithin
+func (r *SimpleRTree) FindIDsWithin(x, y, dsquared float64, dst []int) []int {
+	r.findPointsWithin(x, y, dsquared, func(i int) {
+		dst = append(dst, r.id(i))
+	})
+	return dst
+}
+
+// SearchPolygonIDs appends to dst the ids of the points inside the polygon given by ring. See SearchPolygon
+func (r *SimpleRTree) SearchPolygonIDs(ring FlatPoints, dst []int) []int {
+	r.searchPolygon(ring, func(i int) {
+		dst = append(dst, r.id(i))
+	})
+	return dst
+}
+
+func (r *SimpleRTree) id(i int) int {
+	if r.ids == nil {
+		return i
+	}
+	return r.ids[i]
+}
@@ -0,0 +1,60 @@
+package SimpleRTree
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestSimpleRTree_LoadWithIDs(t *testing.T) {
+	const size = 2000
+	points := make([]float64, size*2)
+	for i := 0; i < 2*size; i++ {
+		points[i] = rand.Float64()
+	}
+	original := FlatPoints(append(make([]float64, 0, len(points)), points...))
+	fp := FlatPoints(append(make([]float64, 0, len(points)), points...))
+	fp2 := FlatPoints(append(make([]float64, 0, len(points)), points...))
+	r := New().LoadWithIDs(fp, nil)
+	rH := NewWithOptions(Options{TreeType: HILBERT}).LoadWithIDs(fp2, nil)
+	for _, tree := range []*SimpleRTree{r, rH} {
+		for i := 0; i < 100; i++ {
+			x, y := rand.Float64(), rand.Float64()
+			id, x1, y1, _ := tree.FindNearestPointID(x, y)
+			x2, y2 := original.GetPointAt(id)
+			assert.Equal(t, x1, x2)
+			assert.Equal(t, y1, y2)
+
+			ids := tree.FindKNearestIDs(x, y, 5, nil)
+			assert.Equal(t, original.linearKClosestPoints(x, y, 5), original.pointsOf(ids))
+
+			ids = tree.FindIDsWithin(x, y, 0.001, ids[0:0])
+			assert.Equal(t, original.linearPointsWithin(x, y, 0.001), original.pointsOf(ids).sorted())
+
+			ids = tree.SearchIDs(x, y, x+0.05, y+0.05, ids[0:0])
+			assert.Equal(t, original.linearSearch(x, y, x+0.05, y+0.05), original.pointsOf(ids).sorted())
+		}
+	}
+}
+
+func TestSimpleRTree_LoadWithIDsDuplicates(t *testing.T) {
+	points := []float64{1, 1, 0, 0, 1, 1, 2, 2}
+	ids := []int{10, 11, 12, 13}
+	r := New().LoadWithIDs(FlatPoints(points), ids)
+	found := r.FindIDsWithin(1, 1, 0, nil)
+	sort.Ints(found)
+	assert.Equal(t, []int{10, 12}, found, "Duplicated coordinates can be told apart")
+	id, _, _, _, ok := r.FindNearestPointIDWithin(0.1, 0.1, 1)
+	assert.True(t, ok)
+	assert.Equal(t, 11, id)
+}
+
+func (fp FlatPoints) pointsOf(ids []int) FlatPoints {
+	result := FlatPoints{}
+	for _, id := range ids {
+		x, y := fp.GetPointAt(id)
+		result = append(result, x, y)
+	}
+	return result
+}
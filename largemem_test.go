@@ -0,0 +1,41 @@
+//go:build largemem
+
+package SimpleRTree
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+	"unsafe"
+)
+
+// Tests in this file reserve more than 4Gb, they only run with
+//  go test -tags largemem
+
+func TestLargeIndexesQuery(t *testing.T) {
+	if unsafe.Sizeof(uintptr(0)) < 8 {
+		t.Skip("offsets beyond 32 bits need a 64 bit platform")
+	}
+	// The root points to a node whose byte offset does not fit in 32 bits.
+	// Only the pages of the two nodes used are touched, the rest of the array is never written
+	far := uint32(math.MaxUint32/node_size) + 1
+	nodes := make([]rNode, int(far)+1)
+	nodes[0] = rNode{nodeType: default_node, nChildren: 1, firstChild: far}
+	nodes[far] = rNode{nodeType: preleaf_node, nChildren: 2, firstChild: 0, BBox: newVectorBBox(0, 0, 1, 1)}
+	for _, unsafeMode := range []bool{false, true} {
+		r := &SimpleRTree{
+			options: Options{MAX_ENTRIES: DEFAULT_MAX_ENTRIES, UnsafeConcurrencyMode: unsafeMode},
+			nodes:   nodes,
+			points:  FlatPoints{0, 0, 1, 1},
+			built:   true,
+			height:  2,
+		}
+		r.initQueues(nil)
+		x1, y1, d1 := r.FindNearestPoint(0.9, 0.8)
+		assert.Equal(t, FlatPoints{1, 1}, FlatPoints{x1, y1})
+		assert.InDelta(t, 0.05, d1, 1e-12)
+		assert.Equal(t, FlatPoints{1, 1, 0, 0}, r.FindKNearestPoints(0.9, 0.8, 2, nil))
+		assert.Equal(t, FlatPoints{0, 0}, r.Search(-1, -1, 0.5, 0.5, nil))
+		assert.Equal(t, FlatPoints{0, 0}, r.FindPointsWithin(0, 0.1, 0.5, nil))
+	}
+}
@@ -0,0 +1,147 @@
+package SimpleRTree
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"os"
+	"unsafe"
+)
+
+// mappableLayout is true if the in memory layout of nodes, points and ids is the same as the serialized one,
+// in that case OpenMapped uses the mapped file directly
+var mappableLayout = mmapSupported &&
+	isLittleEndian() &&
+	unsafe.Sizeof(int(0)) == 8 &&
+	unsafe.Sizeof(rNode{}) == format_node_size &&
+	unsafe.Offsetof(rNode{}.nChildren) == 1 &&
+	unsafe.Offsetof(rNode{}.firstChild) == 4 &&
+	unsafe.Offsetof(rNode{}.BBox) == 8
+
+func isLittleEndian() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}
+
+// OpenMapped opens a tree written with WriteTo by mapping the file into memory, queries read the mapped pages directly
+// so the tree is not copied into the heap. Header and checksum are checked as in ReadFrom.
+// The tree must be closed with Close once it is no longer needed.
+// On platforms without mmap, or if the layout of the file does not match the memory layout (big endian), the file is read into the heap
+//  r, err := SimpleRTree.OpenMapped("tree.bin")
+//  defer r.Close()
+//  x1, y1, d1 := r.FindNearestPoint(x, y)
+func OpenMapped(path string) (*SimpleRTree, error) {
+	return OpenMappedWithOptions(path, Options{})
+}
+
+// OpenMappedWithOptions works as OpenMapped with the given options, TreeType and MAX_ENTRIES are read from the file.
+// As in ReadFrom, Geodesic and Metric must match the ones of the written tree
+// Points of a mapped tree are never copied, so CopyPoints has no effect
+func OpenMappedWithOptions(path string, o Options) (*SimpleRTree, error) {
+	r, err := NewWithOptionsErr(o)
+	if err != nil {
+		return nil, err
+	}
+	r.options.CopyPoints = false
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !mappableLayout {
+		n, err := r.ReadFrom(bufio.NewReader(f))
+		if err != nil {
+			return nil, err
+		}
+		if n != info.Size() {
+			return nil, fmt.Errorf("%w: file size %d, expected %d", ErrInvalidFormat, info.Size(), n)
+		}
+		return r, nil
+	}
+	if info.Size() < format_header_size+4 || info.Size() > math.MaxInt {
+		return nil, fmt.Errorf("%w: file size %d", ErrInvalidFormat, info.Size())
+	}
+	data, err := mmapFile(f, int(info.Size()))
+	if err != nil {
+		return nil, err
+	}
+	if err := r.setMapped(data); err != nil {
+		munmapFile(data)
+		return nil, err
+	}
+	return r, nil
+}
+
+// Close unmaps the file of a tree opened with OpenMapped, the tree cannot be used afterwards. For other trees it does nothing
+func (r *SimpleRTree) Close() error {
+	if r.mapped == nil {
+		return nil
+	}
+	err := munmapFile(r.mapped)
+	r.mapped = nil
+	r.nodes, r.points, r.ids, r.deleted = nil, nil, nil, nil
+	return err
+}
+
+// setMapped checks the serialized tree in data and points the slices of the tree to it
+func (r *SimpleRTree) setMapped(data []byte) error {
+	h, err := parseHeader(data[0:format_header_size])
+	if err != nil {
+		return err
+	}
+	size := uint64(format_header_size) + h.nNodes*format_node_size + h.nPoints*16 + 4
+	if h.flags&format_flag_ids != 0 {
+		size += h.nPoints * 8
+	}
+	if h.flags&format_flag_deleted != 0 {
+		size += h.nPoints
+	}
+	if size != uint64(len(data)) {
+		return fmt.Errorf("%w: file size %d, expected %d", ErrInvalidFormat, len(data), size)
+	}
+	end := len(data) - 4
+	if binary.LittleEndian.Uint32(data[end:]) != crc32.ChecksumIEEE(data[0:end]) {
+		return fmt.Errorf("%w: checksum mismatch", ErrInvalidFormat)
+	}
+
+	offset := format_header_size
+	var nodes []rNode
+	var points FlatPoints
+	var ids []int
+	var deleted []bool
+	if h.nPoints > 0 {
+		nodes = unsafe.Slice((*rNode)(unsafe.Pointer(&data[offset])), h.nNodes)
+		offset += int(h.nNodes) * format_node_size
+		points = unsafe.Slice((*float64)(unsafe.Pointer(&data[offset])), 2*h.nPoints)
+		offset += int(h.nPoints) * 16
+		if h.flags&format_flag_ids != 0 {
+			ids = unsafe.Slice((*int)(unsafe.Pointer(&data[offset])), h.nPoints)
+			offset += int(h.nPoints) * 8
+		}
+		if h.flags&format_flag_deleted != 0 {
+			count := uint64(0)
+			for _, b := range data[offset : offset+int(h.nPoints)] {
+				// any other value would not be a valid bool
+				if b > 1 {
+					return fmt.Errorf("%w: invalid deleted flag %d", ErrInvalidFormat, b)
+				}
+				count += uint64(b)
+			}
+			if count != h.nDeleted {
+				return fmt.Errorf("%w: %d deleted points, expected %d", ErrInvalidFormat, count, h.nDeleted)
+			}
+			deleted = unsafe.Slice((*bool)(unsafe.Pointer(&data[offset])), h.nPoints)
+		}
+	}
+	if err := r.setDecoded(h, nodes, points, ids, deleted); err != nil {
+		return err
+	}
+	r.mapped = data
+	return nil
+}
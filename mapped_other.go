@@ -0,0 +1,19 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package SimpleRTree
+
+import (
+	"errors"
+	"os"
+)
+
+// Without mmap OpenMapped reads the file into the heap
+const mmapSupported = false
+
+func mmapFile(f *os.File, size int) ([]byte, error) {
+	return nil, errors.New("SimpleRTree: mmap is not supported")
+}
+
+func munmapFile(data []byte) error {
+	return nil
+}
@@ -0,0 +1,113 @@
+package SimpleRTree
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenMapped(t *testing.T) {
+	const size = 2000
+	points := make([]float64, size*2)
+	for i := 0; i < 2*size; i++ {
+		points[i] = rand.Float64()
+	}
+	r := NewWithOptions(Options{TreeType: HILBERT}).LoadWithIDs(FlatPoints(points), nil)
+	r.DeleteByID(3)
+	path := filepath.Join(t.TempDir(), "tree.bin")
+	writeTree(t, r, path)
+
+	for _, options := range []Options{{}, {UnsafeConcurrencyMode: true}} {
+		m, err := OpenMappedWithOptions(path, options)
+		assert.NoError(t, err)
+		assert.Equal(t, mappableLayout, m.mapped != nil)
+		assert.Equal(t, HILBERT, int(m.options.TreeType))
+		for i := 0; i < 100; i++ {
+			x, y := rand.Float64(), rand.Float64()
+			id, x1, y1, d1 := r.FindNearestPointID(x, y)
+			id2, x2, y2, d2 := m.FindNearestPointID(x, y)
+			assert.Equal(t, []interface{}{id, x1, y1, d1}, []interface{}{id2, x2, y2, d2})
+			assert.Equal(t, r.FindKNearestIDs(x, y, 5, nil), m.FindKNearestIDs(x, y, 5, nil))
+			assert.Equal(t, r.FindIDsWithin(x, y, 0.01, nil), m.FindIDsWithin(x, y, 0.01, nil))
+		}
+		// Deletions and compaction do not modify the file, compaction unmaps it
+		x, y := m.points.GetPointAt(10)
+		assert.True(t, m.Delete(x, y))
+		m.Compact()
+		assert.Nil(t, m.mapped)
+		assert.Equal(t, 0., m.TombstoneRatio())
+		_, x1, y1, _ := m.FindNearestPointID(x, y)
+		assert.False(t, x1 == x && y1 == y)
+		assert.NoError(t, m.Close())
+		_, _, _, found := m.FindNearestPointWithin(x, y, 1)
+		assert.True(t, found, "Compacted tree is on the heap")
+	}
+	m, err := OpenMapped(path)
+	assert.NoError(t, err)
+	assert.Equal(t, r.TombstoneRatio(), m.TombstoneRatio())
+	assert.NoError(t, m.Close())
+	assert.NoError(t, m.Close())
+	if mappableLayout {
+		_, _, _, found := m.FindNearestPointWithin(0.5, 0.5, 1)
+		assert.False(t, found, "Closed tree is empty")
+	}
+
+	// Compacting to zero points
+	m, err = OpenMapped(path)
+	assert.NoError(t, err)
+	for i := 0; i < m.points.Len(); i++ {
+		m.DeleteByID(i)
+	}
+	m.Compact()
+	assert.Nil(t, m.mapped)
+	_, _, _, found := m.FindNearestPointWithin(0.5, 0.5, 1)
+	assert.False(t, found)
+	assert.NoError(t, m.Close())
+
+	m, err = OpenMappedWithOptions(path, Options{Geodesic: true})
+	assert.Nil(t, m)
+	assert.True(t, errors.Is(err, ErrMetricMismatch))
+}
+
+func TestOpenMappedInvalid(t *testing.T) {
+	dir := t.TempDir()
+	r := New().Load(FlatPoints{0, 0, 1, 1, 2, 2})
+	path := filepath.Join(dir, "tree.bin")
+	writeTree(t, r, path)
+	valid, err := os.ReadFile(path)
+	assert.NoError(t, err)
+
+	_, err = OpenMapped(filepath.Join(dir, "missing.bin"))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	corrupted := append([]byte{}, valid...)
+	corrupted[format_header_size+10] ^= 0xff
+	testCases := []struct {
+		name     string
+		data     []byte
+		expected error
+	}{
+		{"empty", []byte{}, ErrInvalidFormat},
+		{"truncated", valid[0 : len(valid)-1], ErrInvalidFormat},
+		{"trailing data", append(append([]byte{}, valid...), 0), ErrInvalidFormat},
+		{"checksum", corrupted, ErrInvalidFormat},
+	}
+	for _, tc := range testCases {
+		path := filepath.Join(dir, "invalid.bin")
+		assert.NoError(t, os.WriteFile(path, tc.data, 0644))
+		m, err := OpenMapped(path)
+		assert.Nil(t, m, tc.name)
+		assert.True(t, errors.Is(err, tc.expected), "%s: expected %v got %v", tc.name, tc.expected, err)
+	}
+}
+
+func writeTree(t *testing.T, r *SimpleRTree, path string) {
+	f, err := os.Create(path)
+	assert.NoError(t, err)
+	_, err = r.WriteTo(f)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+}
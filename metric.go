@@ -0,0 +1,220 @@
+package SimpleRTree
+
+import (
+	"math"
+	"unsafe"
+)
+
+// Metric measures distances for nearest, k nearest and within queries, see Options.Metric.
+// Distances do not need to be actual distances, any increasing function of them (like the square) works,
+// and dsquared arguments and returned distances are in the units of Distance
+type Metric interface {
+	// Distance returns the distance between x1, y1 and x2, y2
+	Distance(x1, y1, x2, y2 float64) float64
+	// BBoxDistances returns a lower bound mind of the distance from x, y to any point of the bbox and an upper bound maxd
+	// of the distance to the closest point of the bbox, knowing that every side of the bbox has a point on it.
+	// maxd can be +Inf if there is no such bound
+	BBoxDistances(minX, minY, maxX, maxY, x, y float64) (mind, maxd float64)
+}
+
+// Euclidean is the squared euclidean distance. It is the default metric, trees with it use the fast path,
+// where distances are computed inline (and in assembly)
+type Euclidean struct{}
+
+func (Euclidean) Distance(x1, y1, x2, y2 float64) float64 {
+	return computeLeafDistance(x1, y1, x2, y2)
+}
+
+func (Euclidean) BBoxDistances(minX, minY, maxX, maxY, x, y float64) (mind, maxd float64) {
+	return computeDistances(rVectorBBox{minX, minY, maxX, maxY}, x, y)
+}
+
+// Manhattan is the L1 distance |x1 - x2| + |y1 - y2|
+type Manhattan struct{}
+
+func (Manhattan) Distance(x1, y1, x2, y2 float64) float64 {
+	return math.Abs(x1-x2) + math.Abs(y1-y2)
+}
+
+func (Manhattan) BBoxDistances(minX, minY, maxX, maxY, x, y float64) (mind, maxd float64) {
+	outX, nearX, farX := axisDistances(minX, maxX, x)
+	outY, nearY, farY := axisDistances(minY, maxY, y)
+	return outX + outY, math.Min(nearX+farY, farX+nearY)
+}
+
+// Chebyshev is the L∞ distance max(|x1 - x2|, |y1 - y2|)
+type Chebyshev struct{}
+
+func (Chebyshev) Distance(x1, y1, x2, y2 float64) float64 {
+	return math.Max(math.Abs(x1-x2), math.Abs(y1-y2))
+}
+
+func (Chebyshev) BBoxDistances(minX, minY, maxX, maxY, x, y float64) (mind, maxd float64) {
+	outX, nearX, farX := axisDistances(minX, maxX, x)
+	outY, nearY, farY := axisDistances(minY, maxY, y)
+	return math.Max(outX, outY), math.Min(math.Max(nearX, farY), math.Max(farX, nearY))
+}
+
+// WeightedEuclidean is the squared euclidean distance with a weight per axis, WX * (x1 - x2)**2 + WY * (y1 - y2)**2,
+// for data whose axes have different scales. Weights must be positive
+type WeightedEuclidean struct {
+	WX, WY float64
+}
+
+func (w WeightedEuclidean) Distance(x1, y1, x2, y2 float64) float64 {
+	return w.WX*(x1-x2)*(x1-x2) + w.WY*(y1-y2)*(y1-y2)
+}
+
+func (w WeightedEuclidean) BBoxDistances(minX, minY, maxX, maxY, x, y float64) (mind, maxd float64) {
+	outX, nearX, farX := axisDistances(minX, maxX, x)
+	outY, nearY, farY := axisDistances(minY, maxY, y)
+	mind = w.WX*outX*outX + w.WY*outY*outY
+	maxd = math.Min(w.WX*nearX*nearX+w.WY*farY*farY, w.WX*farX*farX+w.WY*nearY*nearY)
+	return mind, maxd
+}
+
+// axisDistances returns the distance from v to the interval [min, max], 0 if v is inside,
+// and the distances to the closest and furthest ends of the interval
+func axisDistances(min, max, v float64) (out, near, far float64) {
+	near, far = sortFloats(math.Abs(v-min), math.Abs(v-max))
+	if v < min || v > max {
+		out = near
+	}
+	return out, near, far
+}
+
+// metric returns the metric selected by the options, nil for the fast path of euclidean distance
+func (o Options) metric() Metric {
+	if o.Geodesic {
+		return haversine{}
+	}
+	if _, ok := o.Metric.(Euclidean); ok {
+		return nil
+	}
+	return o.Metric
+}
+
+// pointDistance returns the distance between x, y and px, py with the metric m, or the euclidean distance if m is nil
+func pointDistance(m Metric, px, py, x, y float64) float64 {
+	if m == nil {
+		return computeLeafDistance(px, py, x, y)
+	}
+	return m.Distance(x, y, px, py)
+}
+
+// findNearestPointMetric works as findNearestPointWithQueue for trees with a Metric.
+// The search stops once the closest item in the queue is a point
+func (r *SimpleRTree) findNearestPointMetric(sq searchQueue, m Metric, x, y, dsquared float64, trace func(nodeIndex int)) (index int, d1 float64, found bool, _ searchQueue) {
+	sq = sq[0:0]
+	deleted := r.deleted
+	unsafeRootNode := uintptr(unsafe.Pointer(&r.nodes[0]))
+	sq = append(sq, searchQueueItem{node: unsafeRootNode, distance: 0})
+
+	for sq.Len() > 0 {
+		sq.PreparePop()
+		item := sq[sq.Len()-1]
+		sq = sq[0 : sq.Len()-1]
+
+		node := (*rNode)(unsafe.Pointer(item.node))
+		if node == nil { // Leaf
+			// no item left in the queue can be closer
+			return item.index, item.distance, true, sq
+		}
+		if trace != nil {
+			trace(int((item.node - unsafeRootNode) / node_size))
+		}
+		first := int(node.firstChild)
+		for i := first; i < first+int(node.nChildren); i++ {
+			if node.nodeType == preleaf_node {
+				px, py := r.points.GetPointAt(i)
+				d := m.Distance(x, y, px, py)
+				if d <= dsquared && (deleted == nil || !deleted[i]) {
+					sq = append(sq, searchQueueItem{index: i, distance: d})
+					dsquared = d
+				}
+				continue
+			}
+			b := &r.nodes[i].BBox
+			mind, maxd := m.BBoxDistances(b[vector_bbox_min_x], b[vector_bbox_min_y], b[vector_bbox_max_x], b[vector_bbox_max_y], x, y)
+			if mind <= dsquared {
+				sq = append(sq, searchQueueItem{node: uintptr(unsafe.Pointer(&r.nodes[i])), distance: mind})
+				if maxd < dsquared && deleted == nil {
+					dsquared = maxd
+				}
+			}
+		}
+	}
+	return index, d1, found, sq
+}
+
+// findKNearestPointsMetric works as findKNearestPointsWithin
+func (r *SimpleRTree) findKNearestPointsMetric(m Metric, x, y, dsquared float64, k int, visit func(index int)) {
+	sq := r.getQueue()
+	deleted := r.deleted
+	sq = append(sq, searchQueueItem{node: uintptr(unsafe.Pointer(&r.nodes[0])), distance: 0})
+
+	found := 0
+	for sq.Len() > 0 {
+		sq.PreparePop()
+		item := sq[sq.Len()-1]
+		sq = sq[0 : sq.Len()-1]
+
+		node := (*rNode)(unsafe.Pointer(item.node))
+		if node == nil { // Leaf
+			visit(item.index)
+			found++
+			if found == k {
+				break
+			}
+			continue
+		}
+		first := int(node.firstChild)
+		for i := first; i < first+int(node.nChildren); i++ {
+			if node.nodeType == preleaf_node {
+				px, py := r.points.GetPointAt(i)
+				d := m.Distance(x, y, px, py)
+				if d <= dsquared && (deleted == nil || !deleted[i]) {
+					sq = append(sq, searchQueueItem{index: i, distance: d})
+				}
+				continue
+			}
+			if d := bboxLowerBound(m, &r.nodes[i].BBox, x, y); d <= dsquared {
+				sq = append(sq, searchQueueItem{node: uintptr(unsafe.Pointer(&r.nodes[i])), distance: d})
+			}
+		}
+	}
+	r.putQueue(sq)
+}
+
+// findPointsWithinMetric works as findPointsWithin
+func (r *SimpleRTree) findPointsWithinMetric(m Metric, x, y, dsquared float64, visit func(index int)) {
+	sq := r.getQueue()
+	deleted := r.deleted
+	sq = append(sq, searchQueueItem{node: uintptr(unsafe.Pointer(&r.nodes[0]))})
+
+	for sq.Len() > 0 {
+		item := sq[sq.Len()-1]
+		sq = sq[0 : sq.Len()-1]
+
+		node := (*rNode)(unsafe.Pointer(item.node))
+		first := int(node.firstChild)
+		for i := first; i < first+int(node.nChildren); i++ {
+			if node.nodeType == preleaf_node {
+				px, py := r.points.GetPointAt(i)
+				if m.Distance(x, y, px, py) <= dsquared && (deleted == nil || !deleted[i]) {
+					visit(i)
+				}
+				continue
+			}
+			if bboxLowerBound(m, &r.nodes[i].BBox, x, y) <= dsquared {
+				sq = append(sq, searchQueueItem{node: uintptr(unsafe.Pointer(&r.nodes[i]))})
+			}
+		}
+	}
+	r.putQueue(sq)
+}
+
+func bboxLowerBound(m Metric, b *rVectorBBox, x, y float64) float64 {
+	mind, _ := m.BBoxDistances(b[vector_bbox_min_x], b[vector_bbox_min_y], b[vector_bbox_max_x], b[vector_bbox_max_y], x, y)
+	return mind
+}
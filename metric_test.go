@@ -0,0 +1,183 @@
+package SimpleRTree
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+var testMetrics = map[string]Metric{
+	"euclidean":          Euclidean{},
+	"manhattan":          Manhattan{},
+	"chebyshev":          Chebyshev{},
+	"weighted euclidean": WeightedEuclidean{WX: 1, WY: 25},
+}
+
+func TestMetric_BBoxDistances(t *testing.T) {
+	for name, m := range testMetrics {
+		for n := 0; n < 1000; n++ {
+			minX, maxX := sortFloats(rand.Float64(), rand.Float64())
+			minY, maxY := sortFloats(rand.Float64(), rand.Float64())
+			x, y := rand.Float64()*2-0.5, rand.Float64()*2-0.5
+			mind, maxd := m.BBoxDistances(minX, minY, maxX, maxY, x, y)
+
+			// lower bound is reached at the closest point of the bbox
+			closestX, closestY := math.Max(minX, math.Min(maxX, x)), math.Max(minY, math.Min(maxY, y))
+			assert.InDelta(t, m.Distance(x, y, closestX, closestY), mind, 1e-12, name)
+			px, py := minX+rand.Float64()*(maxX-minX), minY+rand.Float64()*(maxY-minY)
+			assert.True(t, mind <= m.Distance(x, y, px, py), name)
+
+			// whatever the points on the sides of the bbox, one of them is within the upper bound
+			lon, lat := minX+rand.Float64()*(maxX-minX), minY+rand.Float64()*(maxY-minY)
+			closest := math.Inf(1)
+			for _, p := range [4][2]float64{{lon, minY}, {lon, maxY}, {minX, lat}, {maxX, lat}} {
+				closest = math.Min(closest, m.Distance(x, y, p[0], p[1]))
+			}
+			assert.True(t, closest <= maxd, name)
+		}
+	}
+	mind, maxd := Euclidean{}.BBoxDistances(0, 0, 1, 1, 2, 3)
+	expectedMind, expectedMaxd := computeDistances(rVectorBBox{0, 0, 1, 1}, 2, 3)
+	assert.Equal(t, expectedMind, mind)
+	assert.Equal(t, expectedMaxd, maxd)
+}
+
+func TestSimpleRTree_Metric(t *testing.T) {
+	const size = 3000
+	points := make(FlatPoints, 0, 2*size)
+	for i := 0; i < size; i++ {
+		points = append(points, rand.Float64(), rand.Float64())
+	}
+	queries := make(FlatPoints, 0, 100)
+	for i := 0; i < 50; i++ {
+		queries = append(queries, rand.Float64()*1.2-0.1, rand.Float64()*1.2-0.1)
+	}
+	for name, m := range testMetrics {
+		within := m.Distance(0, 0, 0.05, 0.05)
+		for _, options := range []Options{{}, {TreeType: HILBERT}, {MAX_ENTRIES: 4}} {
+			options.CopyPoints = true
+			options.Metric = m
+			r := NewWithOptions(options).Load(points)
+			for i := 0; i < queries.Len(); i++ {
+				x, y := queries.GetPointAt(i)
+				x1, y1, d1 := r.FindNearestPoint(x, y)
+				x2, y2, d2 := points.linearMetricClosestPoint(m, x, y)
+				assert.Equal(t, FlatPoints{x2, y2}, FlatPoints{x1, y1}, name)
+				assert.Equal(t, d2, d1, name)
+
+				assert.Equal(t, points.linearMetricKClosestPoints(m, x, y, 7), r.FindKNearestPoints(x, y, 7, nil), name)
+				assert.Equal(t, points.linearMetricPointsWithin(m, x, y, within), r.FindPointsWithin(x, y, within, FlatPoints{}).sorted(), name)
+				found := r.FindPointsWithin(x, y, within, FlatPoints{})
+				r.SortByDistance(x, y, found)
+				assert.Equal(t, r.FindKNearestPoints(x, y, found.Len(), FlatPoints{}), found, name)
+			}
+
+			// upper bounds are not used once there are deleted points
+			remaining := points
+			for i := 0; i < 10; i++ {
+				x, y := queries.GetPointAt(i)
+				x1, y1, _ := r.FindNearestPoint(x, y)
+				r.Delete(x1, y1)
+				remaining = remaining.without(x1, y1)
+				x2, y2, _ := remaining.linearMetricClosestPoint(m, x, y)
+				x1, y1, _ = r.FindNearestPoint(x, y)
+				assert.Equal(t, FlatPoints{x2, y2}, FlatPoints{x1, y1}, name)
+			}
+		}
+
+		d := NewDynamicWithOptions(Options{Metric: m})
+		for i := 0; i < 1000; i++ {
+			d.Insert(points.GetPointAt(i))
+		}
+		for i := 0; i < queries.Len(); i++ {
+			x, y := queries.GetPointAt(i)
+			x1, y1, d1 := d.FindNearestPoint(x, y)
+			x2, y2, d2 := points[0:2000].linearMetricClosestPoint(m, x, y)
+			assert.Equal(t, FlatPoints{x2, y2}, FlatPoints{x1, y1}, name)
+			assert.Equal(t, d2, d1, name)
+			assert.Equal(t, points[0:2000].linearMetricKClosestPoints(m, x, y, 7), d.FindKNearestPoints(x, y, 7, nil), name)
+		}
+	}
+
+	// Closest point depends on the metric
+	r := NewWithOptions(Options{Metric: Chebyshev{}}).Load(FlatPoints{3, 3, 0, 3.5})
+	x1, y1, d1 := r.FindNearestPoint(0, 0)
+	assert.Equal(t, FlatPoints{3, 3, 3}, FlatPoints{x1, y1, d1})
+	sorted := FlatPoints{0, 3.5, 3, 3}
+	r.SortByDistance(0, 0, sorted)
+	assert.Equal(t, FlatPoints{3, 3, 0, 3.5}, sorted, "Sorted with the metric of the tree")
+	sorted.SortByDistance(0, 0)
+	assert.Equal(t, FlatPoints{0, 3.5, 3, 3}, sorted, "Sorted with euclidean distance")
+	r = NewWithOptions(Options{Metric: Manhattan{}}).Load(FlatPoints{3, 3, 0, 3.5})
+	x1, y1, d1 = r.FindNearestPoint(0, 0)
+	assert.Equal(t, FlatPoints{0, 3.5, 3.5}, FlatPoints{x1, y1, d1})
+	r = NewWithOptions(Options{Metric: WeightedEuclidean{WX: 1, WY: 100}}).Load(FlatPoints{0, 1, 5, 0})
+	x1, y1, d1 = r.FindNearestPoint(0, 0)
+	assert.Equal(t, FlatPoints{5, 0, 25}, FlatPoints{x1, y1, d1})
+
+	assert.Panics(t, func() { NewBBoxRTreeWithOptions(Options{Metric: Manhattan{}}) })
+	assert.NotPanics(t, func() { NewBBoxRTreeWithOptions(Options{Metric: Euclidean{}}) })
+}
+
+func (fp FlatPoints) linearMetricClosestPoint(m Metric, x, y float64) (x1, y1, d float64) {
+	d = math.Inf(1)
+	for i := 0; i < fp.Len(); i++ {
+		x2, y2 := fp.GetPointAt(i)
+		if d1 := m.Distance(x, y, x2, y2); d1 < d {
+			d = d1
+			x1 = x2
+			y1 = y2
+		}
+	}
+	return
+}
+
+func (fp FlatPoints) linearMetricPointsWithin(m Metric, x, y, dsquared float64) FlatPoints {
+	result := FlatPoints{}
+	for i := 0; i < fp.Len(); i++ {
+		x1, y1 := fp.GetPointAt(i)
+		if m.Distance(x, y, x1, y1) <= dsquared {
+			result = append(result, x1, y1)
+		}
+	}
+	return result.sorted()
+}
+
+// linearMetricKClosestPoints assumes that there are no ties in the distances
+func (fp FlatPoints) linearMetricKClosestPoints(m Metric, x, y float64, k int) FlatPoints {
+	result := append(FlatPoints{}, fp...)
+	sort.Sort(distanceSorter{points: result, x: x, y: y, metric: m})
+	if result.Len() > k {
+		result = result[0 : 2*k]
+	}
+	return result
+}
+
+// without returns a copy of the points without x, y
+func (fp FlatPoints) without(x, y float64) FlatPoints {
+	result := FlatPoints{}
+	for i := 0; i < fp.Len(); i++ {
+		if x1, y1 := fp.GetPointAt(i); x1 != x || y1 != y {
+			result = append(result, x1, y1)
+		}
+	}
+	return result
+}
+
+func BenchmarkSimpleRTree_FindNearestPointMetric(b *testing.B) {
+	const size = 1000000
+	points := make(FlatPoints, 0, 2*size)
+	for i := 0; i < size; i++ {
+		points = append(points, rand.Float64(), rand.Float64())
+	}
+	for _, name := range []string{"manhattan", "chebyshev", "weighted euclidean"} {
+		r := NewWithOptions(Options{UnsafeConcurrencyMode: true, Metric: testMetrics[name]}).Load(points)
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				r.FindNearestPoint(rand.Float64(), rand.Float64())
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package SimpleRTree
+
+// SearchPolygon appends to dst all the points inside the polygon given by ring, a flat array with its vertices.
+// The ring can be closed (last vertex equal to the first) or not, and it can be concave or self intersecting,
+// in which case the even-odd rule is used. Points on the border of the polygon are included.
+// Nodes whose bbox is outside the polygon are skipped and nodes whose bbox is inside it are added without
+// testing their points, so only points close to the border are tested against the polygon.
+// Points are appended in no particular order
+//
+//	zone := SimpleRTree.FlatPoints{0, 0, 4, 0, 4, 4, 2, 1, 0, 4}
+//	dst = r.SearchPolygon(zone, dst[0:0])
+func (r *SimpleRTree) SearchPolygon(ring FlatPoints, dst FlatPoints) FlatPoints {
+	r.searchPolygon(ring, func(i int) {
+		x1, y1 := r.points.GetPointAt(i)
+		dst = append(dst, x1, y1)
+	})
+	return dst
+}
+
+func (r *SimpleRTree) searchPolygon(ring FlatPoints, visit func(index int)) {
+	p := newPolygon(ring)
+	if len(r.nodes) == 0 || p.ring.Len() < 3 {
+		return
+	}
+	// edges crossing each node are stored one level after the other, see searchPolygonNode. There are at most height+1 levels
+	buffer := r.getEdges()
+	if size := (r.height + 1) * p.ring.Len(); cap(*buffer) < size {
+		*buffer = make([]int, 0, size)
+	}
+	edges := (*buffer)[0:p.ring.Len()]
+	for i := range edges {
+		edges[i] = i
+	}
+	r.searchPolygonNode(&p, 0, edges, visit)
+	r.putEdges(buffer)
+}
+
+// getEdges returns the buffer of edges for the query, it is kept on the tree as the search queue
+func (r *SimpleRTree) getEdges() *[]int {
+	if r.options.UnsafeConcurrencyMode {
+		return &r.unsafeEdges
+	}
+	if buffer, ok := r.edgesPool.Get().(*[]int); ok {
+		return buffer
+	}
+	return new([]int)
+}
+
+// putEdges returns a pooled buffer once the query is done. In UnsafeConcurrencyMode the buffer is r.unsafeEdges itself,
+// so if searchPolygon had to grow it the tree already holds the bigger one
+func (r *SimpleRTree) putEdges(buffer *[]int) {
+	if !r.options.UnsafeConcurrencyMode {
+		r.edgesPool.Put(buffer)
+	}
+}
+
+// searchPolygonNode visits the points of a node that crosses the border of the polygon, edges are the edges of the polygon that cross the node.
+// Edges that do not cross a node cannot cross its children, so edges of the children are filtered from them and appended after them
+func (r *SimpleRTree) searchPolygonNode(p *polygon, nodeIndex int, edges []int, visit func(index int)) {
+	n := r.nodes[nodeIndex]
+	first := int(n.firstChild)
+	if n.nodeType == preleaf_node {
+		for i := first; i < first+int(n.nChildren); i++ {
+			x, y := r.points.GetPointAt(i)
+			if (r.deleted == nil || !r.deleted[i]) && p.bbox.containsPoint(x, y) && p.contains(x, y) {
+				visit(i)
+			}
+		}
+		return
+	}
+	for c := first; c < first+int(n.nChildren); c++ {
+		b := r.nodes[c].BBox.toBBox()
+		if !p.bbox.intersects(b) {
+			continue
+		}
+		childEdges := edges[len(edges):len(edges)]
+		for _, e := range edges {
+			if p.edgeIntersects(e, b) {
+				childEdges = append(childEdges, e)
+			}
+		}
+		if len(childEdges) > 0 {
+			r.searchPolygonNode(p, c, childEdges, visit)
+		} else if p.contains((b.MinX+b.MaxX)/2, (b.MinY+b.MaxY)/2) {
+			// the border does not cross the node, so it is either fully inside or fully outside
+			r.visitNode(c, visit)
+		}
+	}
+}
+
+// visitNode visits every point below the node
+func (r *SimpleRTree) visitNode(nodeIndex int, visit func(index int)) {
+	n := r.nodes[nodeIndex]
+	first := int(n.firstChild)
+	for i := first; i < first+int(n.nChildren); i++ {
+		if n.nodeType == default_node {
+			r.visitNode(i, visit)
+		} else if r.deleted == nil || !r.deleted[i] {
+			visit(i)
+		}
+	}
+}
+
+// polygon is a ring of vertices, without the closing vertex
+type polygon struct {
+	ring FlatPoints
+	bbox rBBox
+}
+
+func newPolygon(ring FlatPoints) polygon {
+	n := ring.Len()
+	if n > 1 && ring[0] == ring[2*n-2] && ring[1] == ring[2*n-1] {
+		ring = ring[0 : 2*n-2]
+	}
+	p := polygon{ring: ring[0 : 2*ring.Len()]}
+	if p.ring.Len() == 0 {
+		return p
+	}
+	x0, y0 := p.ring.GetPointAt(0)
+	p.bbox = rBBox{MinX: x0, MinY: y0, MaxX: x0, MaxY: y0}
+	for i := 1; i < p.ring.Len(); i++ {
+		x, y := p.ring.GetPointAt(i)
+		p.bbox = p.bbox.extend(rBBox{MinX: x, MinY: y, MaxX: x, MaxY: y})
+	}
+	return p
+}
+
+// edge returns the ends of the edge i, from vertex i to the next one
+func (p *polygon) edge(i int) (x1, y1, x2, y2 float64) {
+	j := i + 1
+	if j == p.ring.Len() {
+		j = 0
+	}
+	x1, y1 = p.ring.GetPointAt(i)
+	x2, y2 = p.ring.GetPointAt(j)
+	return
+}
+
+// contains tells if x, y is inside the polygon or on its border. It counts the edges crossed by a ray to the right of the point.
+// Whether an edge is crossed is decided with the sign of a cross product instead of computing the intersection, and
+// edges include their lower vertex only, so a ray through a vertex is counted exactly once
+func (p *polygon) contains(x, y float64) bool {
+	inside := false
+	for i := 0; i < p.ring.Len(); i++ {
+		x1, y1, x2, y2 := p.edge(i)
+		cross := (x2-x1)*(y-y1) - (y2-y1)*(x-x1)
+		if cross == 0 && minFloat(x1, x2) <= x && x <= maxFloat(x1, x2) && minFloat(y1, y2) <= y && y <= maxFloat(y1, y2) {
+			return true
+		}
+		// the edge crosses the horizontal line through the point, it is on the right if the point is on the left of the (upward) edge
+		if (y1 > y) != (y2 > y) && (cross > 0) == (y2 > y1) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// edgeIntersects tells if the edge i intersects the bbox b, border included
+func (p *polygon) edgeIntersects(i int, b rBBox) bool {
+	x1, y1, x2, y2 := p.edge(i)
+	if maxFloat(x1, x2) < b.MinX || minFloat(x1, x2) > b.MaxX || maxFloat(y1, y2) < b.MinY || minFloat(y1, y2) > b.MaxY {
+		return false
+	}
+	// bboxes intersect, the edge crosses b unless all the corners are strictly on the same side of it
+	positive, negative := false, false
+	for _, corner := range [4][2]float64{{b.MinX, b.MinY}, {b.MaxX, b.MinY}, {b.MaxX, b.MaxY}, {b.MinX, b.MaxY}} {
+		cross := (x2-x1)*(corner[1]-y1) - (y2-y1)*(corner[0]-x1)
+		positive = positive || cross >= 0
+		negative = negative || cross <= 0
+	}
+	return positive && negative
+}
@@ -0,0 +1,119 @@
+package SimpleRTree
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestPolygon_Contains(t *testing.T) {
+	square := FlatPoints{0, 0, 4, 0, 4, 4, 0, 4}
+	diamond := FlatPoints{2, 0, 4, 2, 2, 4, 0, 2}
+	u := FlatPoints{0, 0, 6, 0, 6, 6, 4, 6, 4, 2, 2, 2, 2, 6, 0, 6}
+	bowTie := FlatPoints{0, 0, 4, 4, 4, 0, 0, 4}
+	testCases := []struct {
+		name     string
+		ring     FlatPoints
+		x, y     float64
+		expected bool
+	}{
+		{"inside", square, 1, 1, true},
+		{"outside", square, 5, 1, false},
+		{"edge", square, 4, 2, true},
+		{"horizontal edge", square, 2, 4, true},
+		{"vertex", square, 0, 0, true},
+		{"ray along horizontal edge", square, -1, 4, false},
+		{"ray through vertex", diamond, -1, 2, false},
+		{"ray through vertex inside", diamond, 1, 2, true},
+		{"ray through top vertex", diamond, 0, 4, false},
+		{"notch", u, 3, 4, false},
+		{"arm", u, 5, 4, true},
+		{"ray through notch", u, 1, 4, true},
+		{"bow tie", bowTie, 1, 2, true},
+		{"bow tie hole", bowTie, 2, 1, false},
+		{"bow tie crossing", bowTie, 2, 2, true},
+	}
+	for _, tc := range testCases {
+		p := newPolygon(tc.ring)
+		assert.Equal(t, tc.expected, p.contains(tc.x, tc.y), tc.name)
+	}
+}
+
+func TestSimpleRTree_SearchPolygon(t *testing.T) {
+	const size = 5000
+	points := make(FlatPoints, 0, 2*size)
+	for i := 0; i < size; i++ {
+		points = append(points, rand.Float64(), rand.Float64())
+	}
+	star := FlatPoints{}
+	for i := 0; i < 10; i++ {
+		radius := 0.45
+		if i%2 == 1 {
+			radius = 0.15
+		}
+		angle := float64(i) * math.Pi / 5
+		star = append(star, 0.5+radius*math.Cos(angle), 0.5+radius*math.Sin(angle))
+	}
+	rings := map[string]FlatPoints{
+		"star":        star,
+		"closed star": append(append(FlatPoints{}, star...), star[0], star[1]),
+		"triangle":    {-0.5, -0.5, 1.5, 0.5, -0.5, 1.5},
+		"large":       {-1, -1, 2, -1, 2, 2, -1, 2},
+		"small":       {0.5, 0.5, 0.51, 0.5, 0.51, 0.51},
+		"outside":     {2, 2, 3, 2, 3, 3},
+	}
+	for _, treeType := range []TreeType{STR, HILBERT} {
+		r := NewWithOptions(Options{TreeType: treeType, CopyPoints: true}).LoadWithIDs(points, nil)
+		for name, ring := range rings {
+			p := newPolygon(ring)
+			expected := FlatPoints{}
+			for i := 0; i < points.Len(); i++ {
+				if p.contains(points.GetPointAt(i)) {
+					expected = append(expected, points[2*i], points[2*i+1])
+				}
+			}
+			assert.Equal(t, expected.sorted(), r.SearchPolygon(ring, FlatPoints{}).sorted(), name)
+			ids := r.SearchPolygonIDs(ring, nil)
+			assert.Equal(t, expected.sorted(), points.pointsOf(ids).sorted(), name)
+		}
+		assert.Equal(t, size, r.SearchPolygon(rings["large"], nil).Len())
+		assert.Equal(t, 0, len(r.SearchPolygon(FlatPoints{0, 0, 1, 1}, nil)), "Degenerate ring")
+
+		x, y := r.points.GetPointAt(0)
+		r.Delete(x, y)
+		assert.Equal(t, size-1, r.SearchPolygon(rings["large"], nil).Len())
+	}
+
+	// Points on the border are included
+	grid := FlatPoints{}
+	for i := 0; i <= 20; i++ {
+		for j := 0; j <= 20; j++ {
+			grid = append(grid, float64(i), float64(j))
+		}
+	}
+	r := NewWithOptions(Options{CopyPoints: true, MAX_ENTRIES: 4}).Load(grid)
+	assert.Equal(t, r.Search(2, 3, 10, 7, nil).sorted(), r.SearchPolygon(FlatPoints{2, 3, 10, 3, 10, 7, 2, 7}, nil).sorted())
+
+	assert.Equal(t, 0, len(New().SearchPolygon(rings["large"], nil)))
+}
+
+func BenchmarkSimpleRTree_SearchPolygon(b *testing.B) {
+	const size = 1000000
+	points := make(FlatPoints, 0, 2*size)
+	for i := 0; i < size; i++ {
+		points = append(points, rand.Float64(), rand.Float64())
+	}
+	r := NewWithOptions(Options{UnsafeConcurrencyMode: true}).Load(points)
+	ring := FlatPoints{}
+	for i := 0; i < 64; i++ {
+		angle := float64(i) * math.Pi / 32
+		ring = append(ring, 0.5+0.05*math.Cos(angle), 0.5+0.05*math.Sin(angle))
+	}
+	dst := FlatPoints{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dst = r.SearchPolygon(ring, dst[0:0])
+	}
+}
@@ -3,7 +3,7 @@ package SimpleRTree
 
 type searchQueueItem struct {
 	node   uintptr   // if nil item carries node
-	px, py float64 // points are not stored in nodes so we need to track them explicitely
+	index  int // points are not stored in nodes so we need to track their position in the points array explicitely
 	distance float64
 }
 
@@ -0,0 +1,130 @@
+package SimpleRTree
+
+import (
+	"log"
+	"math"
+)
+
+// FlatSegments is a flat array of line segments, every four coordinates x1, y1, x2, y2 are a segment
+type FlatSegments []float64
+
+// Len returns the number of segments
+func (fs FlatSegments) Len() int {
+	return len(fs) / 4
+}
+
+// GetSegmentAt returns the coordinates of the ends of the segment i
+func (fs FlatSegments) GetSegmentAt(i int) (x1, y1, x2, y2 float64) {
+	return fs[4*i], fs[4*i+1], fs[4*i+2], fs[4*i+3]
+}
+
+// SegmentRTree indexes line segments, for example the edges of a road network, so that points can be snapped to
+// the closest segment instead of the closest vertex. It is a BBoxRTree over the bboxes of the segments where
+// the last step of the nearest query measures the distance to the segments themselves
+//
+//	segments := SimpleRTree.FlatSegments{0, 0, 10, 0, 0, 5, 10, 5}
+//	s := SimpleRTree.NewSegmentRTree().Load(segments)
+//	index, px, py, d1 := s.FindNearestSegment(3, 1)
+//	// index == 0, px, py == 3, 0, d1 == 1
+type SegmentRTree struct {
+	bboxes   *BBoxRTree
+	segments FlatSegments // segments in the same order as the bboxes of the tree
+}
+
+// NewSegmentRTree returns an instance of a SegmentRTree with default options
+func NewSegmentRTree() *SegmentRTree {
+	return NewSegmentRTreeWithOptions(Options{})
+}
+
+// NewSegmentRTreeWithOptions returns an instance of a SegmentRTree with given options o, it panics if they are not valid.
+// The array of segments is never modified, so CopyPoints has no effect
+func NewSegmentRTreeWithOptions(o Options) *SegmentRTree {
+	return &SegmentRTree{bboxes: NewBBoxRTreeWithOptions(o)}
+}
+
+// Load builds the tree with the given segments. The segments are copied, so the array can be reused afterwards
+func (s *SegmentRTree) Load(segments FlatSegments) *SegmentRTree {
+	if err := s.loadErr(segments); err != nil {
+		log.Fatal(err)
+	}
+	return s
+}
+
+// LoadErr works as Load but returns an error instead of exiting the process.
+// Segments are checked with FlatSegments.Validate before building the tree
+func (s *SegmentRTree) LoadErr(segments FlatSegments) (*SegmentRTree, error) {
+	if err := segments.Validate(); err != nil {
+		return s, err
+	}
+	return s, s.loadErr(segments)
+}
+
+func (s *SegmentRTree) loadErr(segments FlatSegments) error {
+	boxes := make(FlatBBoxes, 4*segments.Len())
+	for i := 0; i < segments.Len(); i++ {
+		x1, y1, x2, y2 := segments.GetSegmentAt(i)
+		boxes[4*i], boxes[4*i+2] = sortFloats(x1, x2)
+		boxes[4*i+1], boxes[4*i+3] = sortFloats(y1, y2)
+	}
+	if err := s.bboxes.loadErr(boxes); err != nil {
+		return err
+	}
+	s.segments = make(FlatSegments, 0, len(segments))
+	for _, id := range s.bboxes.rtree.ids {
+		s.segments = append(s.segments, segments[4*id:4*id+4]...)
+	}
+	return nil
+}
+
+// Destroy frees up resources that are held within the tree, see SimpleRTree.Destroy
+func (s *SegmentRTree) Destroy() {
+	s.bboxes.Destroy()
+}
+
+// Len returns the number of segments in the tree
+func (s *SegmentRTree) Len() int {
+	return s.segments.Len()
+}
+
+// FindNearestSegment returns the position of the closest segment to x, y, the closest point px, py of the segment
+// and the distance squared to it
+//
+//	index, px, py, d1 := s.FindNearestSegment(x, y)
+//	(px - x) * (px - x) + (py - y) * (py - y) == d1
+func (s *SegmentRTree) FindNearestSegment(x, y float64) (index int, px, py, d1 float64) {
+	index, px, py, d1, _ = s.FindNearestSegmentWithin(x, y, math.Inf(1))
+	return
+}
+
+// FindNearestSegmentWithin works as FindNearestSegment for segments within the distance squared dsquared.
+// In case there is no segment within dsquared found will return false
+func (s *SegmentRTree) FindNearestSegmentWithin(x, y, dsquared float64) (index int, px, py, d1 float64, found bool) {
+	position, d1, found := s.bboxes.findNearestWithin(x, y, dsquared, func(position int) float64 {
+		_, _, d := s.segments.closestPoint(position, x, y)
+		return d
+	})
+	if !found {
+		return
+	}
+	px, py, d1 = s.segments.closestPoint(position, x, y)
+	return s.bboxes.rtree.ids[position], px, py, d1, true
+}
+
+// closestPoint returns the closest point of the segment i to x, y and the distance squared to it
+func (fs FlatSegments) closestPoint(i int, x, y float64) (px, py, d float64) {
+	x1, y1, x2, y2 := fs.GetSegmentAt(i)
+	dx, dy := x2-x1, y2-y1
+	t := 0.
+	if l := dx*dx + dy*dy; l > 0 {
+		t = ((x-x1)*dx + (y-y1)*dy) / l
+	}
+	switch {
+	case t <= 0:
+		px, py = x1, y1
+	case t >= 1:
+		px, py = x2, y2
+	default:
+		px, py = x1+t*dx, y1+t*dy
+	}
+	return px, py, computeLeafDistance(px, py, x, y)
+}
@@ -0,0 +1,101 @@
+package SimpleRTree
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestSegmentRTree(t *testing.T) {
+	const size = 2000
+	segments := make(FlatSegments, 0, 4*size)
+	for i := 0; i < size; i++ {
+		x, y := rand.Float64(), rand.Float64()
+		segments = append(segments, x, y, x+0.1*(rand.Float64()-0.5), y+0.1*(rand.Float64()-0.5))
+	}
+	original := append(FlatSegments{}, segments...)
+	for _, options := range []Options{{}, {MAX_ENTRIES: 4}, {TreeType: HILBERT}, {UnsafeConcurrencyMode: true}} {
+		s := NewSegmentRTreeWithOptions(options).Load(segments)
+		assert.Equal(t, original, segments, "Segments are not modified")
+		assert.Equal(t, size, s.Len())
+
+		for i := 0; i < 100; i++ {
+			x, y := 1.2*rand.Float64()-0.1, 1.2*rand.Float64()-0.1
+			index, px, py, d := s.FindNearestSegment(x, y)
+			expected := segments.linearSegmentDistance(x, y)
+			assert.Equal(t, expected, d)
+			px2, py2, d2 := segments.closestPoint(index, x, y)
+			assert.Equal(t, []float64{px2, py2, d2}, []float64{px, py, d})
+
+			_, _, _, _, found := s.FindNearestSegmentWithin(x, y, expected)
+			assert.True(t, found)
+			_, _, _, _, found = s.FindNearestSegmentWithin(x, y, expected/2)
+			assert.False(t, found)
+		}
+	}
+
+	// The nearest vertex belongs to a different segment
+	s := NewSegmentRTree().Load(FlatSegments{0, 0, 10, 0, 3.5, 2, 3.5, 3})
+	index, px, py, d := s.FindNearestSegment(3, 1)
+	assert.Equal(t, []float64{0, 3, 0, 1}, []float64{float64(index), px, py, d})
+
+	empty := NewSegmentRTree().Load(FlatSegments{})
+	_, _, _, _, found := empty.FindNearestSegmentWithin(0, 0, math.Inf(1))
+	assert.False(t, found)
+}
+
+func TestFlatSegments_ClosestPoint(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		x, y                 float64
+		segment              FlatSegments
+		expectedX, expectedY float64
+		expectedDistance     float64
+	}{
+		{"interior", 1, 1, FlatSegments{0, 0, 2, 0}, 1, 0, 1},
+		{"before first end", -1, 1, FlatSegments{0, 0, 2, 0}, 0, 0, 2},
+		{"after second end", 4, 0, FlatSegments{0, 0, 2, 0}, 2, 0, 4},
+		{"diagonal", 0, 2, FlatSegments{0, 0, 2, 2}, 1, 1, 2},
+		{"degenerate", 1, 1, FlatSegments{0, 0, 0, 0}, 0, 0, 2},
+	}
+	for _, tc := range testCases {
+		px, py, d := tc.segment.closestPoint(0, tc.x, tc.y)
+		assert.Equal(t, []float64{tc.expectedX, tc.expectedY, tc.expectedDistance}, []float64{px, py, d}, tc.name)
+	}
+}
+
+func TestSegmentRTree_LoadErr(t *testing.T) {
+	_, err := NewSegmentRTree().LoadErr(FlatSegments{0, 0, 1, 1, 2, math.NaN(), 1, 3})
+	var ve *ValidationError
+	assert.ErrorAs(t, err, &ve)
+	assert.Equal(t, 1, ve.Index)
+	assert.Equal(t, ErrNaNCoordinate, ve.Kind)
+	assert.Equal(t, "SimpleRTree: NaN coordinate: segment 1", err.Error())
+
+	_, err = NewSegmentRTree().LoadErr(FlatSegments{0, 0, 1, 1, 2})
+	assert.ErrorIs(t, err, ErrSegmentLength)
+}
+
+func (fs FlatSegments) linearSegmentDistance(x, y float64) float64 {
+	d := math.Inf(1)
+	for i := 0; i < fs.Len(); i++ {
+		_, _, d1 := fs.closestPoint(i, x, y)
+		d = math.Min(d, d1)
+	}
+	return d
+}
+
+func BenchmarkSegmentRTree_FindNearestSegment(b *testing.B) {
+	const size = 1000000
+	segments := make(FlatSegments, 0, 4*size)
+	for i := 0; i < size; i++ {
+		x, y := rand.Float64(), rand.Float64()
+		segments = append(segments, x, y, x+0.001*(rand.Float64()-0.5), y+0.001*(rand.Float64()-0.5))
+	}
+	s := NewSegmentRTreeWithOptions(Options{UnsafeConcurrencyMode: true}).Load(segments)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.FindNearestSegment(rand.Float64(), rand.Float64())
+	}
+}
@@ -0,0 +1,435 @@
+package SimpleRTree
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+// Binary format of a serialized tree, version 2. All values are little endian.
+//
+//	header, format_header_size bytes
+//	  0  magic "SRTREE\x00\x00"
+//	  8  version uint32
+//	  12 flags uint32, format_flag_ids | format_flag_deleted
+//	  16 tree type uint32
+//	  20 MAX_ENTRIES uint32
+//	  24 height uint32
+//	  28 metric uint32, one of format_metric_*. Version 1 had it reserved
+//	  32 number of nodes uint64
+//	  40 number of points uint64
+//	  48 number of deleted points uint64
+//	  56 reserved uint64
+//	nodes, format_node_size bytes each
+//	  0  node type uint8
+//	  1  number of children uint8
+//	  2  reserved uint16
+//	  4  first child uint32
+//	  8  bbox 4 float64
+//	points, 2 float64 each
+//	ids, int64 each. Only if format_flag_ids
+//	deleted, uint8 each, 1 if the point is deleted. Only if format_flag_deleted
+//	CRC32 (IEEE) of all the previous bytes, uint32
+const (
+	format_version     = 2
+	format_header_size = 64
+	format_node_size   = 40
+	format_max_height  = 64
+
+	format_flag_ids     = 1 << 0
+	format_flag_deleted = 1 << 1
+)
+
+// Kind of distance of the tree, from Options.Geodesic and Options.Metric
+const (
+	format_metric_euclidean = iota
+	format_metric_geodesic
+	format_metric_manhattan
+	format_metric_chebyshev
+	format_metric_weighted_euclidean
+	format_metric_custom
+)
+
+var format_metric_names = [...]string{"euclidean", "geodesic", "manhattan", "chebyshev", "weighted euclidean", "custom"}
+
+var format_magic = [8]byte{'S', 'R', 'T', 'R', 'E', 'E', 0, 0}
+
+// WriteTo writes the tree to w, so it can be loaded later with ReadFrom without building it again.
+// It returns the number of bytes written. Points, ids and deleted points are kept, as well as TreeType and MAX_ENTRIES.
+// The metric itself is not stored, only its kind (euclidean, geodesic, manhattan...), so that ReadFrom can check it.
+//
+//	f, _ := os.Create("tree.bin")
+//	_, err := r.WriteTo(bufio.NewWriter(f))
+func (r *SimpleRTree) WriteTo(w io.Writer) (int64, error) {
+	e := newEncoder(w)
+	var flags uint32
+	if r.ids != nil {
+		flags |= format_flag_ids
+	}
+	if r.deleted != nil {
+		flags |= format_flag_deleted
+	}
+	copy(e.next(len(format_magic)), format_magic[:])
+	e.uint32(format_version)
+	e.uint32(flags)
+	e.uint32(uint32(r.options.TreeType))
+	e.uint32(uint32(r.options.MAX_ENTRIES))
+	e.uint32(uint32(r.height))
+	e.uint32(r.options.metricKind())
+	e.uint64(uint64(len(r.nodes)))
+	e.uint64(uint64(r.points.Len()))
+	e.uint64(uint64(r.nDeleted))
+	e.uint64(0)
+
+	for _, n := range r.nodes {
+		e.uint8(uint8(n.nodeType))
+		e.uint8(n.nChildren)
+		e.uint16(0)
+		e.uint32(n.firstChild)
+		for _, c := range n.BBox {
+			e.float64(c)
+		}
+	}
+	for _, c := range r.points {
+		e.float64(c)
+	}
+	for _, id := range r.ids {
+		e.uint64(uint64(int64(id)))
+	}
+	for _, d := range r.deleted {
+		if d {
+			e.uint8(1)
+		} else {
+			e.uint8(0)
+		}
+	}
+	return e.finish()
+}
+
+// ReadFrom loads a tree written by WriteTo. It must be called on a new tree, options of the tree are kept
+// except for TreeType and MAX_ENTRIES which are read from src. It returns the number of bytes read.
+// It returns ErrInvalidFormat if the data is not a valid tree (including checksum mismatch) and ErrUnsupportedVersion
+// if it was written by a newer version of the library.
+// Geodesic and Metric are not read from src, the tree must be created with the same ones as the written tree,
+// otherwise ErrMetricMismatch is returned. Only the kind of metric is checked, not the weights of WeightedEuclidean
+// or which custom Metric was used
+//
+//	r := SimpleRTree.NewWithOptions(SimpleRTree.Options{UnsafeConcurrencyMode: true})
+//	_, err := r.ReadFrom(bufio.NewReader(f))
+func (r *SimpleRTree) ReadFrom(src io.Reader) (int64, error) {
+	if r.built {
+		return 0, ErrAlreadyBuilt
+	}
+	d := newDecoder(src)
+	header := d.read(format_header_size)
+	if d.err != nil {
+		return d.n, d.err
+	}
+	d.crc.Write(header)
+	h, err := parseHeader(header)
+	if err != nil {
+		return d.n, err
+	}
+	le := binary.LittleEndian
+	nNodes, nPoints := h.nNodes, h.nPoints
+
+	// Slices grow as data is read, so a corrupted header cannot allocate more memory than the data present
+	nodes := make([]rNode, 0, initialCap(nNodes))
+	d.section(int(nNodes), format_node_size, func(b []byte) {
+		n := rNode{
+			nodeType:   nodeType(b[0]),
+			nChildren:  b[1],
+			firstChild: le.Uint32(b[4:]),
+		}
+		for j := range n.BBox {
+			n.BBox[j] = math.Float64frombits(le.Uint64(b[8+8*j:]))
+		}
+		nodes = append(nodes, n)
+	})
+	points := make(FlatPoints, 0, initialCap(2*nPoints))
+	d.section(2*int(nPoints), 8, func(b []byte) {
+		points = append(points, math.Float64frombits(le.Uint64(b)))
+	})
+	var ids []int
+	if h.flags&format_flag_ids != 0 {
+		ids = make([]int, 0, initialCap(nPoints))
+		d.section(int(nPoints), 8, func(b []byte) {
+			id := int64(le.Uint64(b))
+			if int64(int(id)) != id && d.err == nil {
+				d.err = fmt.Errorf("%w: id %d does not fit in int", ErrInvalidFormat, id)
+			}
+			ids = append(ids, int(id))
+		})
+	}
+	var deleted []bool
+	count := 0
+	if h.flags&format_flag_deleted != 0 {
+		deleted = make([]bool, 0, initialCap(nPoints))
+		d.section(int(nPoints), 1, func(b []byte) {
+			deleted = append(deleted, b[0] != 0)
+			if b[0] != 0 {
+				count++
+			}
+		})
+	}
+	sum := d.crc.Sum32()
+	trailer := d.read(4)
+	if d.err != nil {
+		return d.n, d.err
+	}
+	if le.Uint32(trailer) != sum {
+		return d.n, fmt.Errorf("%w: checksum mismatch", ErrInvalidFormat)
+	}
+	if uint64(count) != h.nDeleted {
+		return d.n, fmt.Errorf("%w: %d deleted points, expected %d", ErrInvalidFormat, count, h.nDeleted)
+	}
+	return d.n, r.setDecoded(h, nodes, points, ids, deleted)
+}
+
+// formatHeader holds the fields of the header of a serialized tree
+type formatHeader struct {
+	flags                     uint32
+	treeType                  TreeType
+	maxEntries, height        uint32
+	metric                    uint32
+	nNodes, nPoints, nDeleted uint64
+}
+
+// parseHeader reads and checks the header, the sizes are checked so they can be safely converted to int
+func parseHeader(header []byte) (h formatHeader, err error) {
+	if string(header[0:8]) != string(format_magic[:]) {
+		return h, fmt.Errorf("%w: wrong magic number", ErrInvalidFormat)
+	}
+	le := binary.LittleEndian
+	if version := le.Uint32(header[8:]); version != format_version {
+		return h, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+	h = formatHeader{
+		flags:      le.Uint32(header[12:]),
+		treeType:   TreeType(le.Uint32(header[16:])),
+		maxEntries: le.Uint32(header[20:]),
+		height:     le.Uint32(header[24:]),
+		metric:     le.Uint32(header[28:]),
+		nNodes:     le.Uint64(header[32:]),
+		nPoints:    le.Uint64(header[40:]),
+		nDeleted:   le.Uint64(header[48:]),
+	}
+	switch {
+	case h.treeType != STR && h.treeType != HILBERT && h.treeType != ZORDER:
+		return h, fmt.Errorf("%w: unknown tree type %d", ErrInvalidFormat, h.treeType)
+	case h.maxEntries < 2 || h.maxEntries > MAX_POSSIBLE_SIZE:
+		return h, fmt.Errorf("%w: MAX_ENTRIES %d", ErrInvalidFormat, h.maxEntries)
+	case h.height > format_max_height:
+		return h, fmt.Errorf("%w: height %d", ErrInvalidFormat, h.height)
+	case h.metric > format_metric_custom:
+		return h, fmt.Errorf("%w: unknown metric %d", ErrInvalidFormat, h.metric)
+	case h.nPoints > maxPoints || h.nPoints > math.MaxInt/2 || h.nNodes > math.MaxUint32 || h.nNodes > math.MaxInt || h.nDeleted > h.nPoints:
+		return h, fmt.Errorf("%w: %d nodes, %d points and %d deleted", ErrInvalidFormat, h.nNodes, h.nPoints, h.nDeleted)
+	case (h.nNodes == 0) != (h.nPoints == 0):
+		return h, fmt.Errorf("%w: %d nodes for %d points", ErrInvalidFormat, h.nNodes, h.nPoints)
+	}
+	return h, nil
+}
+
+// setDecoded validates the nodes and sets up the tree with the decoded data
+func (r *SimpleRTree) setDecoded(h formatHeader, nodes []rNode, points FlatPoints, ids []int, deleted []bool) error {
+	if kind := r.options.metricKind(); h.metric != kind {
+		return fmt.Errorf("%w: tree was written with %s distance, options have %s", ErrMetricMismatch, format_metric_names[h.metric], format_metric_names[kind])
+	}
+	if err := validateNodes(nodes, int(h.nPoints), int(h.maxEntries)); err != nil {
+		return err
+	}
+	r.options.TreeType = h.treeType
+	r.options.MAX_ENTRIES = int(h.maxEntries)
+	if h.nPoints == 0 {
+		return nil
+	}
+	r.built = true
+	r.nodes = nodes
+	r.points = points
+	r.ids = ids
+	r.deleted = deleted
+	r.nDeleted = int(h.nDeleted)
+	r.height = int(h.height)
+	r.initQueues(nil)
+	return nil
+}
+
+// validateNodes checks that the references to children are within bounds and that every node is only reached once
+// from the root. Queries follow the references with pointer arithmetic, so they cannot be trusted blindly
+func validateNodes(nodes []rNode, nPoints, maxEntries int) error {
+	if len(nodes) == 0 {
+		return nil
+	}
+	visited := make([]bool, len(nodes))
+	visited[0] = true
+	stack := []int{0}
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[0 : len(stack)-1]
+		n := nodes[i]
+		// first is kept as uint64 so that first+count cannot overflow on 32 bit platforms
+		first, count := uint64(n.firstChild), int(n.nChildren)
+		if count > maxEntries {
+			return fmt.Errorf("%w: node %d has %d children", ErrInvalidFormat, i, count)
+		}
+		switch n.nodeType {
+		case preleaf_node:
+			if first+uint64(count) > uint64(nPoints) {
+				return fmt.Errorf("%w: node %d points out of bounds", ErrInvalidFormat, i)
+			}
+		case default_node:
+			if first+uint64(count) > uint64(len(nodes)) {
+				return fmt.Errorf("%w: node %d children out of bounds", ErrInvalidFormat, i)
+			}
+			for c := int(first); c < int(first)+count; c++ {
+				if visited[c] {
+					return fmt.Errorf("%w: node %d is reached twice", ErrInvalidFormat, c)
+				}
+				visited[c] = true
+				stack = append(stack, c)
+			}
+		default:
+			return fmt.Errorf("%w: node %d has unknown type %d", ErrInvalidFormat, i, n.nodeType)
+		}
+	}
+	return nil
+}
+
+// metricKind returns the format_metric_* of the options
+func (o Options) metricKind() uint32 {
+	switch o.metric().(type) {
+	case nil:
+		return format_metric_euclidean
+	case haversine:
+		return format_metric_geodesic
+	case Manhattan:
+		return format_metric_manhattan
+	case Chebyshev:
+		return format_metric_chebyshev
+	case WeightedEuclidean:
+		return format_metric_weighted_euclidean
+	}
+	return format_metric_custom
+}
+
+func initialCap(n uint64) int {
+	if n > 1<<16 {
+		return 1 << 16
+	}
+	return int(n)
+}
+
+// encoder writes little endian values to w in chunks, keeping the checksum of everything written
+type encoder struct {
+	w   io.Writer
+	crc hash.Hash32
+	buf []byte
+	n   int64
+	err error
+}
+
+func newEncoder(w io.Writer) *encoder {
+	return &encoder{w: w, crc: crc32.NewIEEE(), buf: make([]byte, 0, 1<<16)}
+}
+
+// next returns the following size bytes of the buffer to be filled
+func (e *encoder) next(size int) []byte {
+	if len(e.buf)+size > cap(e.buf) {
+		e.flush()
+	}
+	l := len(e.buf)
+	e.buf = e.buf[0 : l+size]
+	return e.buf[l:]
+}
+
+func (e *encoder) flush() {
+	if e.err == nil && len(e.buf) > 0 {
+		e.crc.Write(e.buf)
+		var n int
+		n, e.err = e.w.Write(e.buf)
+		e.n += int64(n)
+	}
+	e.buf = e.buf[0:0]
+}
+
+// finish writes the pending bytes and the checksum
+func (e *encoder) finish() (int64, error) {
+	e.flush()
+	if e.err != nil {
+		return e.n, e.err
+	}
+	var trailer [4]byte
+	binary.LittleEndian.PutUint32(trailer[:], e.crc.Sum32())
+	n, err := e.w.Write(trailer[:])
+	e.n += int64(n)
+	return e.n, err
+}
+
+func (e *encoder) uint8(v uint8) {
+	e.next(1)[0] = v
+}
+
+func (e *encoder) uint16(v uint16) {
+	binary.LittleEndian.PutUint16(e.next(2), v)
+}
+
+func (e *encoder) uint32(v uint32) {
+	binary.LittleEndian.PutUint32(e.next(4), v)
+}
+
+func (e *encoder) uint64(v uint64) {
+	binary.LittleEndian.PutUint64(e.next(8), v)
+}
+
+func (e *encoder) float64(v float64) {
+	e.uint64(math.Float64bits(v))
+}
+
+// decoder reads exactly the bytes of the tree from r, so r can hold more data afterwards
+type decoder struct {
+	r   io.Reader
+	crc hash.Hash32
+	buf []byte
+	n   int64
+	err error
+}
+
+func newDecoder(r io.Reader) *decoder {
+	return &decoder{r: r, crc: crc32.NewIEEE(), buf: make([]byte, 1<<16)}
+}
+
+// read returns the next size bytes, size must not be larger than the buffer.
+// They are not added to the checksum, section does that
+func (d *decoder) read(size int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	b := d.buf[0:size]
+	n, err := io.ReadFull(d.r, b)
+	d.n += int64(n)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = fmt.Errorf("%w: %v", ErrInvalidFormat, io.ErrUnexpectedEOF)
+	}
+	d.err = err
+	return b
+}
+
+// section reads count elements of size bytes and calls f with each of them
+func (d *decoder) section(count, size int, f func(b []byte)) {
+	perChunk := len(d.buf) / size
+	for i := 0; i < count && d.err == nil; i += perChunk {
+		n := minInt(perChunk, count-i)
+		b := d.read(n * size)
+		if d.err != nil {
+			return
+		}
+		d.crc.Write(b)
+		for j := 0; j < n; j++ {
+			f(b[j*size : (j+1)*size])
+		}
+	}
+}
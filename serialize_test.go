@@ -0,0 +1,183 @@
+package SimpleRTree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"hash/crc32"
+	"math/rand"
+	"testing"
+)
+
+func TestSimpleRTree_WriteToReadFrom(t *testing.T) {
+	const size = 2000
+	points := make([]float64, size*2)
+	for i := 0; i < 2*size; i++ {
+		points[i] = rand.Float64()
+	}
+	original := FlatPoints(points)
+	trees := []*SimpleRTree{
+		NewWithOptions(Options{CopyPoints: true}).Load(original),
+		NewWithOptions(Options{CopyPoints: true, TreeType: HILBERT, MAX_ENTRIES: 16}).LoadWithIDs(original, nil),
+		NewWithOptions(Options{CopyPoints: true, MAX_ENTRIES: 2}).LoadWithIDs(original, nil),
+	}
+	for i := 0; i < size; i += 3 {
+		trees[1].DeleteByID(i)
+	}
+	for _, r := range trees {
+		var buf bytes.Buffer
+		n, err := r.WriteTo(&buf)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(buf.Len()), n)
+		// Trailing data is not consumed
+		buf.WriteString("next")
+
+		for _, options := range []Options{{}, {UnsafeConcurrencyMode: true}} {
+			r2 := NewWithOptions(options)
+			n2, err := r2.ReadFrom(bytes.NewReader(buf.Bytes()))
+			assert.NoError(t, err)
+			assert.Equal(t, n, n2)
+			assert.Equal(t, r.options.TreeType, r2.options.TreeType)
+			assert.Equal(t, r.options.MAX_ENTRIES, r2.options.MAX_ENTRIES)
+			assert.Equal(t, r.TombstoneRatio(), r2.TombstoneRatio())
+			for i := 0; i < 100; i++ {
+				x, y := rand.Float64(), rand.Float64()
+				id, x1, y1, d1 := r.FindNearestPointID(x, y)
+				id2, x2, y2, d2 := r2.FindNearestPointID(x, y)
+				assert.Equal(t, []interface{}{id, x1, y1, d1}, []interface{}{id2, x2, y2, d2})
+				assert.Equal(t, r.FindKNearestIDs(x, y, 5, nil), r2.FindKNearestIDs(x, y, 5, nil))
+				assert.Equal(t, r.Search(x, y, x+0.1, y+0.1, nil), r2.Search(x, y, x+0.1, y+0.1, nil))
+			}
+			_, err = r2.ReadFrom(bytes.NewReader(buf.Bytes()))
+			assert.True(t, errors.Is(err, ErrAlreadyBuilt))
+		}
+	}
+
+	var buf bytes.Buffer
+	_, err := New().WriteTo(&buf)
+	assert.NoError(t, err)
+	r := New()
+	_, err = r.ReadFrom(&buf)
+	assert.NoError(t, err)
+	_, _, _, found := r.FindNearestPointWithin(0, 0, 1)
+	assert.False(t, found)
+}
+
+func TestSimpleRTree_WriteToReadFromMetric(t *testing.T) {
+	points := FlatPoints{179.9, 0, -170, 0, 170, 0}
+	r := NewWithOptions(Options{CopyPoints: true, Geodesic: true}).Load(points)
+	var buf bytes.Buffer
+	_, err := r.WriteTo(&buf)
+	assert.NoError(t, err)
+
+	r2 := NewWithOptions(Options{Geodesic: true})
+	_, err = r2.ReadFrom(bytes.NewReader(buf.Bytes()))
+	assert.NoError(t, err)
+	x1, y1, d1 := r2.FindNearestPoint(-179.9, 0)
+	assert.Equal(t, FlatPoints{179.9, 0}, FlatPoints{x1, y1}, "Closest point across the antimeridian")
+	_, _, d := r.FindNearestPoint(-179.9, 0)
+	assert.Equal(t, d, d1)
+
+	for _, options := range []Options{{}, {Metric: Manhattan{}}} {
+		_, err = NewWithOptions(options).ReadFrom(bytes.NewReader(buf.Bytes()))
+		assert.True(t, errors.Is(err, ErrMetricMismatch), "Expected %v got %v", ErrMetricMismatch, err)
+	}
+
+	buf.Reset()
+	_, err = NewWithOptions(Options{Metric: Chebyshev{}}).Load(points).WriteTo(&buf)
+	assert.NoError(t, err)
+	_, err = NewWithOptions(Options{Metric: Manhattan{}}).ReadFrom(bytes.NewReader(buf.Bytes()))
+	assert.True(t, errors.Is(err, ErrMetricMismatch))
+	_, err = NewWithOptions(Options{Metric: Chebyshev{}}).ReadFrom(bytes.NewReader(buf.Bytes()))
+	assert.NoError(t, err)
+
+	// Euclidean{} is the default metric
+	buf.Reset()
+	_, err = New().Load(points).WriteTo(&buf)
+	assert.NoError(t, err)
+	_, err = NewWithOptions(Options{Metric: Euclidean{}}).ReadFrom(bytes.NewReader(buf.Bytes()))
+	assert.NoError(t, err)
+}
+
+func TestSimpleRTree_WriteToFormat(t *testing.T) {
+	r := NewWithOptions(Options{TreeType: HILBERT, MAX_ENTRIES: 4}).LoadWithIDs(FlatPoints{1, 2, 3, 4}, []int{7, -8})
+	var buf bytes.Buffer
+	_, err := r.WriteTo(&buf)
+	assert.NoError(t, err)
+	b := buf.Bytes()
+	assert.Equal(t, format_header_size+2*format_node_size+2*16+2*8+4, len(b))
+	assert.Equal(t, []byte("SRTREE\x00\x00"), b[0:8])
+	assert.Equal(t, []byte{2, 0, 0, 0}, b[8:12], "Version is little endian")
+	assert.Equal(t, []byte{format_flag_ids, 0, 0, 0}, b[12:16])
+	assert.Equal(t, []byte{HILBERT, 0, 0, 0}, b[16:20])
+	assert.Equal(t, []byte{4, 0, 0, 0}, b[20:24])
+	assert.Equal(t, []byte{format_metric_euclidean, 0, 0, 0}, b[28:32])
+	assert.Equal(t, uint64(2), binary.LittleEndian.Uint64(b[32:]))
+	assert.Equal(t, uint64(2), binary.LittleEndian.Uint64(b[40:]))
+	pointsStart := format_header_size + 2*format_node_size
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0xf0, 0x3f}, b[pointsStart:pointsStart+8], "1 as little endian float64")
+	assert.Equal(t, uint64(7), binary.LittleEndian.Uint64(b[pointsStart+32:]))
+	assert.Equal(t, int64(-8), int64(binary.LittleEndian.Uint64(b[pointsStart+40:])))
+}
+
+func TestSimpleRTree_ReadFromInvalid(t *testing.T) {
+	points := make(FlatPoints, 200)
+	for i := range points {
+		points[i] = rand.Float64()
+	}
+	var buf bytes.Buffer
+	_, err := New().Load(points).WriteTo(&buf)
+	assert.NoError(t, err)
+	valid := buf.Bytes()
+
+	// withChecksum fixes the checksum after modifying the data, so that validation is reached
+	withChecksum := func(b []byte) []byte {
+		binary.LittleEndian.PutUint32(b[len(b)-4:], crc32.ChecksumIEEE(b[0:len(b)-4]))
+		return b
+	}
+	modified := func(offset int, value byte) []byte {
+		b := append([]byte{}, valid...)
+		b[offset] = value
+		return b
+	}
+	testCases := []struct {
+		name     string
+		data     []byte
+		expected error
+	}{
+		{"empty", []byte{}, ErrInvalidFormat},
+		{"truncated", valid[0 : len(valid)-10], ErrInvalidFormat},
+		{"magic", modified(0, 'X'), ErrInvalidFormat},
+		{"version", modified(8, 1), ErrUnsupportedVersion},
+		{"newer version", modified(8, 3), ErrUnsupportedVersion},
+		{"checksum", modified(format_header_size+100, 0xff), ErrInvalidFormat},
+		{"tree type", withChecksum(modified(16, 7)), ErrInvalidFormat},
+		{"metric", withChecksum(modified(28, 9)), ErrInvalidFormat},
+		{"metric mismatch", withChecksum(modified(28, format_metric_geodesic)), ErrMetricMismatch},
+		{"children out of bounds", withChecksum(modified(format_header_size+7, 0xff)), ErrInvalidFormat},
+		{"too many children", withChecksum(modified(format_header_size+1, MAX_POSSIBLE_SIZE+1)), ErrInvalidFormat},
+		{"node type", withChecksum(modified(format_header_size, 5)), ErrInvalidFormat},
+		{"huge header", withChecksum(modified(39, 0x7f)), ErrInvalidFormat},
+	}
+	for _, tc := range testCases {
+		r := New()
+		_, err := r.ReadFrom(bytes.NewReader(tc.data))
+		assert.True(t, errors.Is(err, tc.expected), "%s: expected %v got %v", tc.name, tc.expected, err)
+		assert.Equal(t, 0, len(r.nodes), tc.name)
+	}
+}
+
+func BenchmarkSimpleRTree_ReadFrom(b *testing.B) {
+	const size = 1000000
+	points := make([]float64, size*2)
+	for i := 0; i < 2*size; i++ {
+		points[i] = rand.Float64()
+	}
+	var buf bytes.Buffer
+	_, _ = New().Load(FlatPoints(points)).WriteTo(&buf)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		_, _ = New().ReadFrom(bytes.NewReader(buf.Bytes()))
+	}
+}
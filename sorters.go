@@ -3,6 +3,7 @@ package SimpleRTree
 type xSorter struct {
 	n                      *rNode
 	points                 FlatPoints
+	ids                    []int // optional, swapped in lockstep with points
 	start, end, bucketSize int
 }
 
@@ -14,6 +15,7 @@ func (s xSorter) Less(i, j int) bool {
 
 func (s xSorter) Swap(i, j int) {
 	s.points.Swap(i+s.start, j+s.start)
+	swapIDs(s.ids, i+s.start, j+s.start)
 }
 
 func (s xSorter) Len() int {
@@ -27,6 +29,7 @@ func (s xSorter) Sort(buffer []int) {
 type ySorter struct {
 	n                      *rNode
 	points                 FlatPoints
+	ids                    []int // optional, swapped in lockstep with points
 	start, end, bucketSize int
 }
 
@@ -38,6 +41,7 @@ func (s ySorter) Less(i, j int) bool {
 
 func (s ySorter) Swap(i, j int) {
 	s.points.Swap(i+s.start, j+s.start)
+	swapIDs(s.ids, i+s.start, j+s.start)
 }
 
 func (s ySorter) Len() int {
@@ -47,3 +51,46 @@ func (s ySorter) Sort(buffer []int) {
 	// we already do the shifting on the sort functions
 	bucketsY(s, s.bucketSize, buffer)
 }
+
+// lexicographicSorter sorts points by x and then by y
+type lexicographicSorter FlatPoints
+
+func (s lexicographicSorter) Less(i, j int) bool {
+	x1, y1 := FlatPoints(s).GetPointAt(i)
+	x2, y2 := FlatPoints(s).GetPointAt(j)
+	return x1 < x2 || x1 == x2 && y1 < y2
+}
+
+func (s lexicographicSorter) Swap(i, j int) {
+	FlatPoints(s).Swap(i, j)
+}
+
+func (s lexicographicSorter) Len() int {
+	return FlatPoints(s).Len()
+}
+
+type distanceSorter struct {
+	points FlatPoints
+	x, y   float64
+	metric Metric // nil for euclidean distance
+}
+
+func (s distanceSorter) Less(i, j int) bool {
+	x1, y1 := s.points.GetPointAt(i)
+	x2, y2 := s.points.GetPointAt(j)
+	return pointDistance(s.metric, x1, y1, s.x, s.y) < pointDistance(s.metric, x2, y2, s.x, s.y)
+}
+
+func (s distanceSorter) Swap(i, j int) {
+	s.points.Swap(i, j)
+}
+
+func (s distanceSorter) Len() int {
+	return s.points.Len()
+}
+
+func swapIDs(ids []int, i, j int) {
+	if ids != nil {
+		ids[i], ids[j] = ids[j], ids[i]
+	}
+}
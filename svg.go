@@ -0,0 +1,138 @@
+package SimpleRTree
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	svg_size   = 1000 // width or height of the image, whichever is larger
+	svg_margin = 10
+)
+
+// svg_level_colors are the stroke colors of the nodes, by depth
+var svg_level_colors = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf"}
+
+// ExportSVG draws the tree as an SVG image, in the style of example.png. The bbox of every node is drawn with a color
+// that depends on its depth and points are drawn as dots, deleted points are skipped.
+// Nodes deeper than maxDepth are not drawn, the root has depth 0. If maxDepth is 0 every node is drawn
+//
+//	f, _ := os.Create("tree.svg")
+//	defer f.Close()
+//	err := r.ExportSVG(f, 0)
+func (r *SimpleRTree) ExportSVG(w io.Writer, maxDepth int) error {
+	return r.exportSVG(w, maxDepth, nil)
+}
+
+// ExportSVGQuery works as ExportSVG and overlays the search done by FindNearestPoint(x, y): every node that is explored
+// is filled in red, so the more overlapping red the more work the query does. The query point and the nearest point found are drawn too
+func (r *SimpleRTree) ExportSVGQuery(w io.Writer, maxDepth int, x, y float64) error {
+	q := svgQuery{x: x, y: y, visited: make([]bool, len(r.nodes))}
+	if len(r.nodes) > 0 {
+		sq := r.getQueue()
+		q.index, _, q.found, sq = r.findNearestPointWithQueue(sq, x, y, math.Inf(1), false, func(nodeIndex int) {
+			q.visited[nodeIndex] = true
+		})
+		r.putQueue(sq)
+	}
+	return r.exportSVG(w, maxDepth, &q)
+}
+
+// svgQuery holds the traversal of a query drawn by ExportSVGQuery
+type svgQuery struct {
+	x, y    float64
+	index   int
+	found   bool
+	visited []bool
+}
+
+// svgCanvas transforms coordinates of the tree into pixels, y grows upwards in the tree and downwards in SVG
+type svgCanvas struct {
+	w          *bufio.Writer
+	minX, maxY float64
+	scale      float64
+	maxDepth   int
+	query      *svgQuery
+}
+
+func (c *svgCanvas) x(x float64) float64 {
+	return svg_margin + (x-c.minX)*c.scale
+}
+
+func (c *svgCanvas) y(y float64) float64 {
+	return svg_margin + (c.maxY-y)*c.scale
+}
+
+func (r *SimpleRTree) exportSVG(w io.Writer, maxDepth int, q *svgQuery) error {
+	extent := rVectorBBox{0, 0, 0, 0}
+	if len(r.nodes) > 0 {
+		extent = r.nodes[0].BBox
+		if q != nil {
+			extent = vectorBBoxExtend(extent, rVectorBBox{q.x, q.y, q.x, q.y})
+		}
+	} else if q != nil {
+		extent = rVectorBBox{q.x, q.y, q.x, q.y}
+	}
+	width, height := extent[vector_bbox_max_x]-extent[vector_bbox_min_x], extent[vector_bbox_max_y]-extent[vector_bbox_min_y]
+	scale := 1.
+	if math.Max(width, height) > 0 {
+		scale = svg_size / math.Max(width, height)
+	}
+	c := &svgCanvas{
+		w:        bufio.NewWriter(w),
+		minX:     extent[vector_bbox_min_x],
+		maxY:     extent[vector_bbox_max_y],
+		scale:    scale,
+		maxDepth: maxDepth,
+		query:    q,
+	}
+	imageWidth, imageHeight := width*scale+2*svg_margin, height*scale+2*svg_margin
+	fmt.Fprintf(c.w, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f">`+"\n", imageWidth, imageHeight, imageWidth, imageHeight)
+	fmt.Fprintf(c.w, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	if len(r.nodes) > 0 {
+		fmt.Fprintf(c.w, `<g fill="none" stroke-width="1">`+"\n")
+		r.drawSVGNode(c, 0, 0)
+		fmt.Fprintf(c.w, "</g>\n")
+		fmt.Fprintf(c.w, `<g fill="black">`+"\n")
+		for i := 0; i < r.points.Len(); i++ {
+			if r.deleted == nil || !r.deleted[i] {
+				px, py := r.points.GetPointAt(i)
+				fmt.Fprintf(c.w, `<circle class="point" cx="%.2f" cy="%.2f" r="1"/>`+"\n", c.x(px), c.y(py))
+			}
+		}
+		fmt.Fprintf(c.w, "</g>\n")
+	}
+	if q != nil {
+		if q.found {
+			px, py := r.points.GetPointAt(q.index)
+			fmt.Fprintf(c.w, `<line x1="%.2f" y1="%.2f" x2="%.2f" y2="%.2f" stroke="red"/>`+"\n", c.x(q.x), c.y(q.y), c.x(px), c.y(py))
+			fmt.Fprintf(c.w, `<circle class="nearest" cx="%.2f" cy="%.2f" r="3" fill="red"/>`+"\n", c.x(px), c.y(py))
+		}
+		fmt.Fprintf(c.w, `<circle class="query" cx="%.2f" cy="%.2f" r="3" fill="none" stroke="red"/>`+"\n", c.x(q.x), c.y(q.y))
+	}
+	fmt.Fprintf(c.w, "</svg>\n")
+	return c.w.Flush()
+}
+
+func (r *SimpleRTree) drawSVGNode(c *svgCanvas, nodeIndex int, depth int) {
+	n := r.nodes[nodeIndex]
+	b := n.BBox
+	class, fill := "node", ""
+	if c.query != nil && c.query.visited[nodeIndex] {
+		class, fill = "node visited", ` fill="red" fill-opacity="0.1"`
+	}
+	fmt.Fprintf(c.w, `<rect class="%s" x="%.2f" y="%.2f" width="%.2f" height="%.2f" stroke="%s"%s/>`+"\n",
+		class,
+		c.x(b[vector_bbox_min_x]), c.y(b[vector_bbox_max_y]),
+		(b[vector_bbox_max_x]-b[vector_bbox_min_x])*c.scale, (b[vector_bbox_max_y]-b[vector_bbox_min_y])*c.scale,
+		svg_level_colors[depth%len(svg_level_colors)], fill)
+	if n.nodeType == preleaf_node || (depth == c.maxDepth && c.maxDepth > 0) {
+		return
+	}
+	first := int(n.firstChild)
+	for i := first; i < first+int(n.nChildren); i++ {
+		r.drawSVGNode(c, i, depth+1)
+	}
+}
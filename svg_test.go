@@ -0,0 +1,127 @@
+package SimpleRTree
+
+import (
+	"bytes"
+	"encoding/xml"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// decodeSVG counts the elements of the image by tag and by class
+func decodeSVG(t *testing.T, b []byte) map[string]int {
+	counts := map[string]int{}
+	d := xml.NewDecoder(bytes.NewReader(b))
+	for {
+		token, err := d.Token()
+		if err != nil {
+			break
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			counts[start.Name.Local]++
+			for _, a := range start.Attr {
+				if a.Name.Local == "class" {
+					counts[a.Value]++
+				}
+			}
+		}
+	}
+	return counts
+}
+
+func TestSimpleRTree_ExportSVG(t *testing.T) {
+	const size = 500
+	points := make(FlatPoints, 2*size)
+	for i := range points {
+		points[i] = rand.Float64()
+	}
+	r := NewWithOptions(Options{CopyPoints: true, MAX_ENTRIES: 4}).Load(points)
+	x, y := r.points.GetPointAt(3)
+	r.Delete(x, y)
+
+	var buf bytes.Buffer
+	assert.NoError(t, r.ExportSVG(&buf, 0))
+	counts := decodeSVG(t, buf.Bytes())
+	assert.Equal(t, 1, counts["svg"])
+	assert.Equal(t, len(r.nodes), counts["node"])
+	assert.Equal(t, size-1, counts["point"])
+	assert.Equal(t, 0, counts["node visited"])
+
+	buf.Reset()
+	assert.NoError(t, r.ExportSVG(&buf, 1))
+	assert.Equal(t, 1+int(r.nodes[0].nChildren), decodeSVG(t, buf.Bytes())["node"])
+
+	buf.Reset()
+	assert.NoError(t, New().ExportSVG(&buf, 0))
+	assert.Equal(t, 1, decodeSVG(t, buf.Bytes())["svg"])
+
+	assert.Equal(t, errWriter, r.ExportSVG(failingWriter{}, 0))
+}
+
+func TestSimpleRTree_ExportSVGQuery(t *testing.T) {
+	const size = 500
+	points := make(FlatPoints, 2*size)
+	for i := range points {
+		points[i] = rand.Float64()
+	}
+	r := NewWithOptions(Options{MAX_ENTRIES: 4}).Load(points)
+	for i := 0; i < 20; i++ {
+		x, y := rand.Float64(), rand.Float64()
+		_, _, d := r.FindNearestPoint(x, y)
+		var visited []int
+		sq := r.getQueue()
+		_, _, _, sq = r.findNearestPointWithQueue(sq, x, y, math.Inf(1), false, func(nodeIndex int) {
+			visited = append(visited, nodeIndex)
+		})
+		r.putQueue(sq)
+		assert.Equal(t, 0, visited[0], "Search starts at the root")
+		for _, nodeIndex := range visited {
+			mind, _ := computeDistances(r.nodes[nodeIndex].BBox, x, y)
+			assert.True(t, mind <= d, "Explored nodes are not further than the nearest point")
+		}
+
+		var buf bytes.Buffer
+		assert.NoError(t, r.ExportSVGQuery(&buf, 0, x, y))
+		counts := decodeSVG(t, buf.Bytes())
+		assert.Equal(t, len(visited), counts["node visited"])
+		assert.Equal(t, len(r.nodes), counts["node"]+counts["node visited"])
+		assert.Equal(t, 1, counts["query"])
+		assert.Equal(t, 1, counts["nearest"])
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, New().ExportSVGQuery(&buf, 0, 1, 1))
+	counts := decodeSVG(t, buf.Bytes())
+	assert.Equal(t, 1, counts["query"])
+	assert.Equal(t, 0, counts["nearest"])
+}
+
+func TestSimpleRTree_ExportSVGCurveTrees(t *testing.T) {
+	const size = 500
+	points := make(FlatPoints, 2*size)
+	for i := range points {
+		points[i] = rand.Float64()*10 + 5
+	}
+	for _, treeType := range []TreeType{HILBERT, ZORDER} {
+		r := NewWithOptions(Options{CopyPoints: true, TreeType: treeType}).Load(points)
+		var buf bytes.Buffer
+		assert.NoError(t, r.ExportSVG(&buf, 0))
+		var image struct {
+			Width   float64 `xml:"width,attr"`
+			Height  float64 `xml:"height,attr"`
+			Circles []struct {
+				CX float64 `xml:"cx,attr"`
+				CY float64 `xml:"cy,attr"`
+			} `xml:"g>circle"`
+		}
+		assert.NoError(t, xml.Unmarshal(buf.Bytes(), &image))
+		assert.Equal(t, float64(svg_size+2*svg_margin), math.Max(image.Width, image.Height), "Canvas fits the extent of the points")
+		assert.Equal(t, size, len(image.Circles))
+		// size of the image is rounded to pixels
+		for _, c := range image.Circles {
+			assert.True(t, c.CX >= svg_margin-0.01 && c.CX <= image.Width-svg_margin+1, "Point inside the canvas %v", c)
+			assert.True(t, c.CY >= svg_margin-0.01 && c.CY <= image.Height-svg_margin+1, "Point inside the canvas %v", c)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package SimpleRTree
+
+import (
+	"fmt"
+	"log"
+	"math"
+)
+
+// Tree is a SimpleRTree where every point carries a value of type T, for example a pointer to a struct or an id.
+// Queries return the values attached to the points found.
+//   points := SimpleRTree.FlatPoints{0, 0, 1, 1}
+//   t := SimpleRTree.NewTree[string]().Load(points, []string{"origin", "corner"})
+//   value, _, _, _ := t.FindNearest(2, 2)
+//   // value == "corner"
+type Tree[T any] struct {
+	rtree    *SimpleRTree
+	payloads []T
+}
+
+// NewTree returns an instance of a Tree with default options
+func NewTree[T any]() *Tree[T] {
+	return &Tree[T]{rtree: New()}
+}
+
+// NewTreeWithOptions returns an instance of a Tree with given options o
+func NewTreeWithOptions[T any](o Options) *Tree[T] {
+	return &Tree[T]{rtree: NewWithOptions(o)}
+}
+
+// Load builds the tree, payloads[i] is the value attached to the point i.
+// Points are reordered as in SimpleRTree.Load, payloads are kept in the original order and
+// are accessed through the ids of the points.
+func (t *Tree[T]) Load(points FlatPoints, payloads []T) *Tree[T] {
+	if err := t.load(points, payloads, false); err != nil {
+		log.Fatal(err)
+	}
+	return t
+}
+
+// LoadErr works as Load but returns an error instead of exiting the process.
+// ErrIDsLength is returned if there is not one payload per point
+func (t *Tree[T]) LoadErr(points FlatPoints, payloads []T) (*Tree[T], error) {
+	return t, t.load(points, payloads, true)
+}
+
+func (t *Tree[T]) load(points FlatPoints, payloads []T, validate bool) error {
+	if len(payloads) != points.Len() {
+		return fmt.Errorf("%w: %d payloads for %d points", ErrIDsLength, len(payloads), points.Len())
+	}
+	if validate {
+		if err := points.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := t.rtree.loadErr(points, identityIDs(points.Len()), false); err != nil {
+		return err
+	}
+	t.payloads = payloads
+	return nil
+}
+
+// Destroy frees up resources that are held within the tree. See SimpleRTree.Destroy
+func (t *Tree[T]) Destroy() {
+	t.rtree.Destroy()
+}
+
+// FindNearest returns the value of the closest point to x and y, together with its coordinates and the distance squared
+func (t *Tree[T]) FindNearest(x, y float64) (value T, x1, y1, d1 float64) {
+	value, x1, y1, d1, _ = t.FindNearestWithin(x, y, math.Inf(1))
+	return
+}
+
+// FindNearestWithin works as FindNearest but only considers points within the distance squared dsquared.
+// In case there is no such point found will return false
+func (t *Tree[T]) FindNearestWithin(x, y, dsquared float64) (value T, x1, y1, d1 float64, found bool) {
+	id, x1, y1, d1, found := t.rtree.FindNearestPointIDWithin(x, y, dsquared)
+	if !found {
+		return
+	}
+	value = t.payloads[id]
+	return
+}
+
+// FindKNearest appends to dst the values of the k closest points in increasing order of distance
+func (t *Tree[T]) FindKNearest(x, y float64, k int, dst []T) []T {
+	return t.FindKNearestWithin(x, y, math.Inf(1), k, dst)
+}
+
+// FindKNearestWithin appends to dst the values of the k closest points within the distance squared dsquared
+func (t *Tree[T]) FindKNearestWithin(x, y, dsquared float64, k int, dst []T) []T {
+	t.rtree.findKNearestPointsWithin(x, y, dsquared, k, func(i int) {
+		dst = append(dst, t.payloads[t.rtree.id(i)])
+	})
+	return dst
+}
+
+// Search appends to dst the values of the points within the given bbox
+func (t *Tree[T]) Search(minX, minY, maxX, maxY float64, dst []T) []T {
+	t.rtree.search(rBBox{MinX: minX, MinY: minY, MaxX: maxX, MaxY: maxY}, func(i int) {
+		dst = append(dst, t.payloads[t.rtree.id(i)])
+	})
+	return dst
+}
+
+// FindWithin appends to dst the values of the points within the distance squared dsquared
+func (t *Tree[T]) FindWithin(x, y, dsquared float64, dst []T) []T {
+	t.rtree.findPointsWithin(x, y, dsquared, func(i int) {
+		dst = append(dst, t.payloads[t.rtree.id(i)])
+	})
+	return dst
+}
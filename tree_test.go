@@ -0,0 +1,61 @@
+package SimpleRTree
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+type testPayload struct {
+	x, y float64
+}
+
+func TestTree_Payloads(t *testing.T) {
+	const size = 2000
+	points := make([]float64, size*2)
+	payloads := make([]*testPayload, size)
+	for i := 0; i < size; i++ {
+		points[2*i] = rand.Float64()
+		points[2*i+1] = rand.Float64()
+		payloads[i] = &testPayload{points[2*i], points[2*i+1]}
+	}
+	original := FlatPoints(append(make([]float64, 0, len(points)), points...))
+	fp := FlatPoints(append(make([]float64, 0, len(points)), points...))
+	tr := NewTree[*testPayload]().Load(FlatPoints(points), payloads)
+	tH := NewTreeWithOptions[*testPayload](Options{TreeType: HILBERT}).Load(fp, payloads)
+	for _, tree := range []*Tree[*testPayload]{tr, tH} {
+		for i := 0; i < 100; i++ {
+			x, y := rand.Float64(), rand.Float64()
+			value, x1, y1, _ := tree.FindNearest(x, y)
+			assert.Equal(t, x1, value.x)
+			assert.Equal(t, y1, value.y)
+
+			values := tree.FindKNearest(x, y, 5, nil)
+			assert.Equal(t, original.linearKClosestPoints(x, y, 5), payloadPoints(values))
+
+			values = tree.FindWithin(x, y, 0.001, values[0:0])
+			assert.Equal(t, original.linearPointsWithin(x, y, 0.001), payloadPoints(values).sorted())
+
+			values = tree.Search(x, y, x+0.05, y+0.05, values[0:0])
+			assert.Equal(t, original.linearSearch(x, y, x+0.05, y+0.05), payloadPoints(values).sorted())
+		}
+	}
+}
+
+func payloadPoints(values []*testPayload) FlatPoints {
+	result := FlatPoints{}
+	for _, v := range values {
+		result = append(result, v.x, v.y)
+	}
+	return result
+}
+
+func ExampleTree_FindNearest() {
+	points := []float64{0, 0, 1, 1, 0, 1}
+	tr := NewTree[string]().Load(FlatPoints(points), []string{"origin", "corner", "top"})
+	value, x1, y1, d := tr.FindNearest(3, 3)
+	fmt.Printf("%s x1 == %f, y1 == %f, d == %f", value, x1, y1, d)
+	// Output:
+	// corner x1 == 1.000000, y1 == 1.000000, d == 8.000000
+}
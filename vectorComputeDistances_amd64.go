@@ -0,0 +1,35 @@
+//go:build !purego
+
+package SimpleRTree
+
+// vectorComputeDistances is the SSE version of computeDistances, it is implemented in Rtree_amd64.s
+func vectorComputeDistances(bbox rVectorBBox, x, y float64) (mind, maxd float64)
+
+// vectorComputeDistances4 computes distances to 4 bboxes at once, separated by stride bytes. It is implemented in Rtree_amd64.s
+//go:noescape
+func vectorComputeDistances4(bbox *rVectorBBox, stride uintptr, x, y float64, mind, maxd *[4]float64)
+
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+func xgetbv() (eax, edx uint32)
+
+// useBatchedKernel is true if the cpu supports AVX2 so vectorComputeDistances4 can be used
+var useBatchedKernel = hasAVX2()
+
+func hasAVX2() bool {
+	maxID, _, _, _ := cpuid(0, 0)
+	if maxID < 7 {
+		return false
+	}
+	_, _, ecx1, _ := cpuid(1, 0)
+	osxsave := ecx1&(1<<27) != 0
+	avx := ecx1&(1<<28) != 0
+	if !osxsave || !avx {
+		return false
+	}
+	// Operating system must save ymm registers
+	if eax, _ := xgetbv(); eax&6 != 6 {
+		return false
+	}
+	_, ebx7, _, _ := cpuid(7, 0)
+	return ebx7&(1<<5) != 0
+}
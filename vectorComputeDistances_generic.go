@@ -0,0 +1,21 @@
+//go:build !amd64 || purego
+
+package SimpleRTree
+
+import "unsafe"
+
+// vectorComputeDistances falls back to the go implementation in architectures without assembly
+func vectorComputeDistances(bbox rVectorBBox, x, y float64) (mind, maxd float64) {
+	return computeDistances(bbox, x, y)
+}
+
+// There is no batched kernel without assembly, children are computed one by one
+const useBatchedKernel = false
+
+func vectorComputeDistances4(bbox *rVectorBBox, stride uintptr, x, y float64, mind, maxd *[4]float64) {
+	f := uintptr(unsafe.Pointer(bbox))
+	for i := 0; i < 4; i++ {
+		mind[i], maxd[i] = computeDistances(*(*rVectorBBox)(unsafe.Pointer(f)), x, y)
+		f = f + stride
+	}
+}